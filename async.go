@@ -0,0 +1,111 @@
+package quickjs
+
+// #include "go-proxy.h"
+import "C"
+import (
+	"reflect"
+	"runtime/debug"
+	"fmt"
+)
+
+// AsyncFunc is a golang func marked by Async.
+type AsyncFunc struct {
+	fn interface{}
+}
+
+// Async marks the golang func fn to be called in its own goroutine, so it appears to JS
+// as a function returning a Promise, which is resolved with the results of fn, or rejected
+// with the error or panic of it. The Promise is settled on the thread owning the context
+// when the pending jobs are run, such as by EvalAwait, CallFuncAwait and Run. The result
+// of Async can be used anywhere a golang func can. A leading context.Context is injected
+// as the one of a sync func, but fn must not take *JsContext or *CallInfo, which can't be
+// used out of the call, or an error is returned when it's bound.
+func Async(fn interface{}) AsyncFunc {
+	return AsyncFunc{fn: fn}
+}
+
+func bindAsyncGoFunc(ctx *C.JSContext, fn interface{}) (C.JSValue, error) {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func {
+		return C.toUndefined(), fmt.Errorf("func expected to be async")
+	}
+	for i:=0; i<t.NumIn(); i++ {
+		if in := t.In(i); in == jsContextType || in == callInfoType {
+			return C.toUndefined(), fmt.Errorf("async func must not take %v", in)
+		}
+	}
+	return wrapGoFunc(ctx, fn, t, goFuncAsync), nil
+}
+
+// create a Promise and call the golang func in a new goroutine, the Promise is settled
+// by a task posted when the func returns.
+func callGoFuncAsync(ctx *C.JSContext, fnVal reflect.Value, this_val C.JSValueConst, argc C.int, argv *C.JSValueConst) C.JSValue {
+	jsCtx := getJsContext(ctx)
+	if jsCtx == nil {
+		return C.toException()
+	}
+
+	var funcs [2]C.JSValue
+	promise := C.JS_NewPromiseCapability(ctx, &funcs[0])
+	if C.JS_IsException(promise) != 0 {
+		return promise
+	}
+	resolve, reject := jsCtx.hold(funcs[0]), jsCtx.hold(funcs[1])
+	settle := func(results []interface{}, err error) {
+		defer jsCtx.release(resolve)
+		defer jsCtx.release(reject)
+		if jsCtx.c == nil {
+			// the context is closed
+			return
+		}
+		jsCtx.settlePromise(jsCtx.held[resolve], jsCtx.held[reject], results, err)
+	}
+
+	// the args are converted on the thread of JS
+	goArgs, err := makeGoArgs(ctx, fnVal.Type(), this_val, argc, argv, true)
+	if err != nil {
+		settle(nil, err)
+		return promise
+	}
+
+	jsCtx.tasks.start()
+	go func() {
+		var results []interface{}
+		var err error
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					err = &GoPanicError{Value: r, Stack: debug.Stack()}
+				}
+			}()
+			results, err = goCallResults(fnVal.Type(), fnVal.Call(goArgs))
+		}()
+		jsCtx.tasks.post(func() error {
+			settle(results, err)
+			return nil
+		})
+	}()
+	return promise
+}
+
+// call resolve with the results, or reject with err.
+func (ctx *jsContext) settlePromise(resolve, reject C.JSValue, results []interface{}, err error) {
+	c := ctx.c
+	var fn, v C.JSValue
+	if err == nil {
+		if v, err = makeGoFuncResult(c, results); err == nil {
+			fn = resolve
+		}
+	}
+	if err != nil {
+		fn = reject
+		if e, ok := err.(*GoPanicError); ok {
+			v = newGoPanicError(c, e)
+		} else {
+			v = newGoError(c, err)
+		}
+	}
+	r := C.JS_Call(c, fn, C.toUndefined(), 1, &v)
+	C.JS_FreeValue(c, r)
+	C.JS_FreeValue(c, v)
+}
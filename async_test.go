@@ -0,0 +1,96 @@
+package quickjs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAsync(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      interface{}
+		script  string
+		want    interface{}
+		wantErr string
+	}{
+		{name: "result", fn: func(a, b int) int { return a + b }, script: `fn(1, 2)`, want: int64(3)},
+		{name: "results", fn: func(s string) (string, int, error) { return s, len(s), nil }, script: `fn("abc")`,
+			want: []interface{}{"abc", int64(3)}},
+		{name: "no result", fn: func() {}, script: `fn().then(v => v === undefined)`, want: true},
+		{name: "in parallel", fn: func(ms int) int { time.Sleep(time.Duration(ms) * time.Millisecond); return ms },
+			script: `Promise.all([fn(20), fn(10)])`, want: []interface{}{int64(20), int64(10)}},
+		{name: "error", fn: func() (int, error) { return 0, errors.New("go failed") }, script: `fn()`, wantErr: "go failed"},
+		{name: "error caught", fn: func() error { return errors.New("go failed") },
+			script: `fn().catch(e => e instanceof Error ? e.message : "not an Error")`, want: "go failed"},
+		{name: "panic", fn: func() int { panic("go panicked") }, script: `fn()`, wantErr: "go panicked"},
+		{name: "context injected", fn: func(goCtx context.Context, s string) string { return goCtx.Value(testKey{}).(string) + s },
+			script: `fn("!")`, want: "value!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			goCtx := context.WithValue(context.Background(), testKey{}, "value")
+			res, err := ctx.EvalAwait(goCtx, tt.script, map[string]interface{}{"fn": Async(tt.fn)})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EvalAwait: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
+
+type testKey struct{}
+
+func TestAsyncReturnsPromise(t *testing.T) {
+	ctx := newTestContext(t)
+	res, err := ctx.Eval(`fn() instanceof Promise`, map[string]interface{}{"fn": Async(func() int { return 1 })})
+	if err != nil || res != true {
+		t.Fatalf("got %v, %v", res, err)
+	}
+}
+
+func TestAsyncCanceled(t *testing.T) {
+	ctx := newTestContext(t)
+	goCtx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	wait := func(goCtx context.Context) error {
+		<-goCtx.Done()
+		return goCtx.Err()
+	}
+	_, err := ctx.EvalAwait(goCtx, `fn()`, map[string]interface{}{"fn": Async(wait)})
+	if !errors.Is(err, context.DeadlineExceeded) && (err == nil || !strings.Contains(err.Error(), "deadline")) {
+		t.Fatalf("got error %v, want the deadline exceeded", err)
+	}
+}
+
+func TestAsyncBadFuncs(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   interface{}
+	}{
+		{"not a func", 1},
+		{"nil", nil},
+		{"JsContext", func(*JsContext) {}},
+		{"CallInfo", func(*CallInfo) error { return nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			if _, err := ctx.Eval(`fn`, map[string]interface{}{"fn": Async(tt.fn)}); err == nil {
+				t.Errorf("error expected")
+			}
+		})
+	}
+}
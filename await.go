@@ -0,0 +1,141 @@
+package quickjs
+
+/*
+#include "quickjs.h"
+#include <stdlib.h>
+
+static int isPromise(JSContext *ctx, JSValue v) {
+	return JS_IsObject(v) && (int)JS_PromiseState(ctx, v) >= 0;
+}
+*/
+import "C"
+import (
+	"context"
+	"unsafe"
+	"fmt"
+)
+
+// EvalAwait is same as EvalContext, but if the result is a Promise, the pending jobs are run
+// until it settles or goCtx is done. The value of a rejected Promise is returned as error.
+func (ctx *JsContext) EvalAwait(goCtx context.Context, script string, env map[string]interface{}) (res interface{}, err error) {
+	ctx.lock()
+	defer ctx.unlock()
+	defer ctx.withGoContext(goCtx)()
+
+	cstr := C.CString(script)
+	defer C.free(unsafe.Pointer(cstr))
+
+	v, e := ctx.evalValue(cstr, C.size_t(len(script)), noname, env)
+	if e != nil {
+		err = e
+		return
+	}
+	defer C.JS_FreeValue(ctx.c, v)
+	return ctx.awaitResult(v)
+}
+
+// CallFuncAwait is same as CallFuncContext, and the returned Promise is awaited as EvalAwait does.
+func (ctx *JsContext) CallFuncAwait(goCtx context.Context, funcName string, args ...interface{}) (res interface{}, err error) {
+	ctx.lock()
+	defer ctx.unlock()
+	defer ctx.withGoContext(goCtx)()
+
+	v, e := ctx.callFuncValue(funcName, args...)
+	if e != nil {
+		err = e
+		return
+	}
+	defer C.JS_FreeValue(ctx.c, v)
+	return ctx.awaitResult(v)
+}
+
+func (ctx *JsContext) awaitResult(v C.JSValue) (res interface{}, err error) {
+	r, e := ctx.await(ctx.goCtx, v)
+	if e != nil {
+		err = e
+		return
+	}
+	defer C.JS_FreeValue(ctx.c, r)
+	res, err = fromJsValue(ctx.c, r)
+	return
+}
+
+func isPromise(ctx *C.JSContext, v C.JSValue) bool {
+	return C.isPromise(ctx, v) != 0
+}
+
+// run the pending jobs until the promise v settles. The result is v itself if it's not
+// a Promise. The result must be freed by the caller.
+func (ctx *jsContext) await(goCtx context.Context, v C.JSValue) (res C.JSValue, err error) {
+	return ctx.awaitJobs(goCtx, v, false)
+}
+
+// run the pending jobs and tasks until the promise of evaluating a module settles, the
+// promise is freed. As a module is evaluated with its imports by one promise, the exceptions
+// thrown by the other jobs don't stop it, they are reported to the console.
+func (ctx *jsContext) awaitModule(p C.JSValue) (res C.JSValue, err error) {
+	c := ctx.c
+	if C.JS_IsException(p) != 0 {
+		err = fromJsException(c)
+		return
+	}
+	defer C.JS_FreeValue(c, p)
+	return ctx.awaitJobs(ctx.goCtx, p, true)
+}
+
+// the exception thrown by a job is returned as error, or reported if reportJobErrors is true.
+func (ctx *jsContext) awaitJobs(goCtx context.Context, v C.JSValue, reportJobErrors bool) (res C.JSValue, err error) {
+	c := ctx.c
+	if !isPromise(c, v) {
+		res = C.JS_DupValue(c, v)
+		return
+	}
+	for {
+		switch C.JS_PromiseState(c, v) {
+		case C.JS_PROMISE_FULFILLED:
+			res = C.JS_PromiseResult(c, v)
+			return
+		case C.JS_PROMISE_REJECTED:
+			reason := C.JS_PromiseResult(c, v)
+			err = fromJsReason(c, reason)
+			return
+		}
+
+		if err = goCtx.Err(); err != nil {
+			return
+		}
+		if C.JS_IsJobPending(ctx.rt.rt) != 0 {
+			var c1 *C.JSContext
+			if C.JS_ExecutePendingJob(ctx.rt.rt, &c1) < 0 {
+				err = fromJsException(c1)
+				if !reportJobErrors {
+					return
+				}
+				ctx.reportError(err)
+				err = nil
+			}
+			continue
+		}
+		ran, e := ctx.runTasks()
+		if e != nil {
+			err = e
+			return
+		}
+		if ran {
+			continue
+		}
+		if !ctx.tasks.busy() {
+			err = fmt.Errorf("the promise is pending with no job to run")
+			return
+		}
+		if err = ctx.tasks.wait(goCtx); err != nil {
+			return
+		}
+	}
+}
+
+// convert the reason of a rejected Promise to error, the ownership of reason is taken.
+func fromJsReason(ctx *C.JSContext, reason C.JSValue) error {
+	C.JS_Throw(ctx, reason)
+	return fromJsException(ctx)
+}
@@ -0,0 +1,101 @@
+package quickjs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvalAwait(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		want    interface{}
+		wantErr string
+	}{
+		{name: "not a promise", script: `1 + 1`, want: int64(2)},
+		{name: "resolved", script: `Promise.resolve("ok")`, want: "ok"},
+		{name: "async", script: `(async () => { await null; return [1, 2]; })()`, want: []interface{}{int64(1), int64(2)}},
+		{name: "timer", script: `new Promise(resolve => setTimeout(() => resolve("late"), 10))`, want: "late"},
+		{name: "rejected with error", script: `Promise.reject(new TypeError("bad value"))`, wantErr: "bad value"},
+		{name: "rejected with value", script: `(async () => { throw "thrown"; })()`, wantErr: "thrown"},
+		{name: "never settled", script: `new Promise(() => {})`, wantErr: "pending"},
+		{name: "syntax error", script: `(`, wantErr: "SyntaxError"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			res, err := ctx.EvalAwait(context.Background(), tt.script, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EvalAwait: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallFuncAwait(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      string
+		args    []interface{}
+		want    interface{}
+		wantErr string
+	}{
+		{name: "sync", fn: "add", args: []interface{}{1, 2}, want: int64(3)},
+		{name: "async", fn: "addLater", args: []interface{}{1, 2}, want: int64(3)},
+		{name: "rejected", fn: "fail", args: []interface{}{"no way"}, wantErr: "no way"},
+		{name: "not a function", fn: "value", wantErr: "not with type function"},
+	}
+	ctx := newTestContext(t)
+	_, err := ctx.Eval(`
+		var value = 1;
+		function add(a, b) { return a + b; }
+		async function addLater(a, b) {
+			await new Promise(resolve => setTimeout(resolve, 1));
+			return a + b;
+		}
+		async function fail(msg) { throw new Error(msg); }
+	`, nil)
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := ctx.CallFuncAwait(context.Background(), tt.fn, tt.args...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CallFuncAwait: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalAwaitCanceled(t *testing.T) {
+	ctx := newTestContext(t)
+	goCtx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := ctx.EvalAwait(goCtx, `new Promise(resolve => setTimeout(resolve, 60 * 1000))`, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,112 @@
+package quickjs
+
+// #include "go-proxy.h"
+import "C"
+import (
+	"context"
+	"reflect"
+)
+
+const channelPrelude = `(function() {
+	class Channel {
+		get [Symbol.toStringTag]() {
+			return "Channel";
+		}
+	}
+
+	// make the wrapper of a golang channel, recv is null if the channel cannot be received
+	// from, send and close are null if it cannot be sent to.
+	return function(recv, send, close) {
+		const ch = new Channel();
+		if (recv !== null) {
+			ch[Symbol.asyncIterator] = function() {
+				return {
+					next() {
+						return recv().then(([value, ok]) => ok ? {value, done: false} : {value: undefined, done: true});
+					},
+					[Symbol.asyncIterator]() {
+						return this;
+					},
+				};
+			};
+		}
+		if (send !== null) {
+			ch.send = function(value) {
+				return send(value);
+			};
+			ch.close = function() {
+				close();
+			};
+		}
+		return Object.freeze(ch);
+	};
+})`
+
+// a golang channel is converted to an object, which is an async iterable if the channel
+// can be received from, and has `send(v)` returning a Promise and `close()` if it can be
+// sent to. The values are received and sent in goroutines, like the async golang funcs.
+func makeChannel(ctx *C.JSContext, ch reflect.Value) (C.JSValue, error) {
+	jsCtx := getJsContext(ctx)
+	if jsCtx == nil {
+		return C.toUndefined(), nil
+	}
+	if ch.IsNil() {
+		return C.toNull(), nil
+	}
+	factory, err := jsCtx.heldPrelude(&jsCtx.channelFactory, "<channel>", channelPrelude)
+	if err != nil {
+		return C.toUndefined(), err
+	}
+
+	var recv, send, close interface{}
+	dir := ch.Type().ChanDir()
+	if dir & reflect.RecvDir != 0 {
+		recv = Async(channelRecv(ch))
+	}
+	if dir & reflect.SendDir != 0 {
+		send = Async(channelSend(ch))
+		close = ch.Close
+	}
+	res, err := callFunc(ctx, factory, recv, send, close)
+	if err == nil && C.JS_IsException(res) != 0 {
+		err = fromJsException(ctx)
+	}
+	return res, err
+}
+
+// receive a value from ch, ok is false if ch is closed.
+func channelRecv(ch reflect.Value) func(context.Context) (interface{}, bool, error) {
+	return func(goCtx context.Context) (v interface{}, ok bool, err error) {
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: ch},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(goCtx.Done())},
+		}
+		chosen, val, recvOK := reflect.Select(cases)
+		if chosen == 1 {
+			err = goCtx.Err()
+			return
+		}
+		if !recvOK {
+			return
+		}
+		return val.Interface(), true, nil
+	}
+}
+
+// send a value converted to the element type to ch, it panics if ch is closed. The func is
+// made with the element type as the arg, so the value is converted like the args of golang funcs.
+func channelSend(ch reflect.Value) interface{} {
+	fnType := reflect.FuncOf([]reflect.Type{goContextType, ch.Type().Elem()}, []reflect.Type{errorType}, false)
+	return reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+		goCtx := args[0].Interface().(context.Context)
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectSend, Chan: ch, Send: args[1]},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(goCtx.Done())},
+		}
+		err := reflect.Zero(errorType)
+		if chosen, _, _ := reflect.Select(cases); chosen == 1 {
+			err = reflect.ValueOf(goCtx.Err()).Convert(errorType)
+		}
+		return []reflect.Value{err}
+	}).Interface()
+}
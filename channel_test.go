@@ -0,0 +1,143 @@
+package quickjs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChannelRecv(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []interface{}
+		script string
+		want   interface{}
+	}{
+		{"iterate", []interface{}{1, "a", true}, `
+			(async () => {
+				const got = [];
+				for await (const v of ch) {
+					got.push(v);
+				}
+				return got;
+			})()`, []interface{}{int64(1), "a", true}},
+		{"closed", nil, `ch[Symbol.asyncIterator]().next().then(r => [r.done, r.value])`, []interface{}{true, nil}},
+		{"break", []interface{}{1, 2, 3}, `
+			(async () => {
+				for await (const v of ch) {
+					return v;
+				}
+			})()`, int64(1)},
+		{"tag", nil, `Object.prototype.toString.call(ch)`, "[object Channel]"},
+		{"recv only", nil, `[typeof ch.send, typeof ch.close]`, []interface{}{"undefined", "undefined"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := make(chan interface{}, len(tt.values))
+			for _, v := range tt.values {
+				ch <- v
+			}
+			close(ch)
+
+			ctx := newTestContext(t)
+			res, err := ctx.EvalAwait(context.Background(), tt.script, map[string]interface{}{"ch": (<-chan interface{})(ch)})
+			if err != nil {
+				t.Fatalf("EvalAwait: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelSend(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+	tests := []struct {
+		name    string
+		ch      interface{}
+		script  string
+		want    interface{}
+		wantErr string
+	}{
+		{name: "int", ch: make(chan int, 2), script: `ch.send(1).then(() => ch.send(2))`, want: []interface{}{1, 2}},
+		{name: "string", ch: make(chan string, 1), script: `ch.send("s")`, want: []interface{}{"s"}},
+		{name: "struct", ch: make(chan point, 1), script: `ch.send({x: 1, y: 2})`, want: []interface{}{point{1, 2}}},
+		{name: "send only", ch: make(chan<- int, 1), script: `
+			if (Symbol.asyncIterator in ch) {
+				throw new Error("a send-only channel is iterable");
+			}
+			ch.send(1)`},
+		{name: "closed", ch: make(chan int), script: `ch.close(); ch.send(1)`, wantErr: "closed channel"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			_, err := ctx.EvalAwait(context.Background(), tt.script, map[string]interface{}{"ch": tt.ch})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EvalAwait: %v", err)
+			}
+
+			// the values sent are received from Go
+			ch := reflect.ValueOf(tt.ch)
+			if ch.Type().ChanDir()&reflect.RecvDir == 0 {
+				return
+			}
+			got := []interface{}{}
+			for ch.Len() > 0 {
+				v, _ := ch.Recv()
+				got = append(got, v.Interface())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelClose(t *testing.T) {
+	ch := make(chan int, 1)
+	ctx := newTestContext(t)
+	if _, err := ctx.EvalAwait(context.Background(), `ch.send(1).then(() => ch.close())`, map[string]interface{}{"ch": ch}); err != nil {
+		t.Fatalf("EvalAwait: %v", err)
+	}
+	if v, ok := <-ch; !ok || v != 1 {
+		t.Errorf("got %v, %v, want 1", v, ok)
+	}
+	if _, ok := <-ch; ok {
+		t.Errorf("the channel is not closed")
+	}
+}
+
+func TestChannelCanceled(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{"recv", `ch[Symbol.asyncIterator]().next()`},
+		{"send", `ch.send(1)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := make(chan int) // nobody sends or receives
+			ctx := newTestContext(t)
+			goCtx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			_, err := ctx.EvalAwait(goCtx, tt.script, map[string]interface{}{"ch": ch})
+			if !errors.Is(err, context.DeadlineExceeded) && (err == nil || !strings.Contains(err.Error(), "deadline")) {
+				t.Fatalf("got error %v, want the deadline exceeded", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+//go:build go1.21
+
+package quickjs
+
+import (
+	"context"
+	"log/slog"
+)
+
+type slogConsole struct {
+	logger *slog.Logger
+}
+
+// NewSlogConsoleHandler makes a ConsoleHandler writing the messages to logger, with the levels
+// "log" and "info" as slog.LevelInfo, "warn" as slog.LevelWarn, "error" as slog.LevelError and
+// "debug" as slog.LevelDebug. Use logger.With() to tag the messages of a context, and the
+// context.Context of the call from Go is passed to logger.
+func NewSlogConsoleHandler(logger *slog.Logger) ConsoleHandler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return slogConsole{logger: logger}
+}
+
+func (s slogConsole) ignoresConsoleArgs() {}
+
+func (s slogConsole) HandleConsole(goCtx context.Context, level string, msg string, _ []interface{}) {
+	lvl := slog.LevelInfo
+	switch level {
+	case "warn":
+		lvl = slog.LevelWarn
+	case "error":
+		lvl = slog.LevelError
+	case "debug":
+		lvl = slog.LevelDebug
+	}
+	s.logger.Log(goCtx, lvl, msg)
+}
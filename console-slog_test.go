@@ -0,0 +1,39 @@
+//go:build go1.21
+
+package quickjs
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogConsole(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	ctx := newTestContext(t)
+	if err := ctx.SetConsole(NewSlogConsoleHandler(logger.With("script", "test"))); err != nil {
+		t.Fatalf("SetConsole: %v", err)
+	}
+	if _, err := ctx.Eval(`console.log("a"); console.warn("b"); console.error("c"); console.debug("d")`, nil); err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	want := []string{
+		"level=INFO msg=a script=test",
+		"level=WARN msg=b script=test",
+		"level=ERROR msg=c script=test",
+		"level=DEBUG msg=d script=test",
+	}
+	if got := strings.Split(strings.TrimSpace(buf.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,190 @@
+package quickjs
+
+// #include "quickjs.h"
+import "C"
+import (
+	"context"
+	"io"
+	"os"
+	"fmt"
+)
+
+const consolePrelude = `(function(output) {
+	const inspect = (v, nested) => {
+		switch (typeof v) {
+		case "string":
+			return nested ? JSON.stringify(v) : v;
+		case "bigint":
+			return v + "n";
+		case "function":
+			return "[Function: " + (v.name || "(anonymous)") + "]";
+		case "object":
+			if (v === null) {
+				return "null";
+			}
+			if (v instanceof Error) {
+				return v.stack ? String(v) + "\n" + v.stack.replace(/\n$/, "") : String(v);
+			}
+			try {
+				const s = JSON.stringify(v);
+				return s === undefined ? String(v) : s;
+			} catch (e) {
+				return String(v);
+			}
+		default:
+			return String(v);
+		}
+	};
+
+	// the args are formatted like console.log of browsers, with %s, %d, %i, %f, %o, %O, %j, %c and %%
+	const format = args => {
+		const parts = [];
+		let i = 0;
+		if (typeof args[0] === "string") {
+			i = 1;
+			parts.push(args[0].replace(/%[sdifoOjc%]/g, m => {
+				if (m === "%%") {
+					return "%";
+				}
+				if (i >= args.length) {
+					return m;
+				}
+				const a = args[i++];
+				switch (m) {
+				case "%s":
+					return typeof a === "string" ? a : inspect(a, true);
+				case "%d":
+				case "%i":
+					return typeof a === "bigint" ? a + "n" : String(m === "%d" ? Number(a) : parseInt(a));
+				case "%f":
+					return String(parseFloat(a));
+				case "%c":
+					return "";
+				default:
+					return inspect(a, true);
+				}
+			}));
+		}
+		for (; i < args.length; i++) {
+			parts.push(inspect(args[i], false));
+		}
+		return parts.join(" ");
+	};
+
+	const console = {};
+	for (const level of ["log", "info", "warn", "error", "debug"]) {
+		console[level] = function(...args) {
+			output(level, format(args), ...args);
+		};
+	}
+	const defineGlobal = (name, value) => {
+		Object.defineProperty(globalThis, name, {value, writable: true, configurable: true});
+	};
+	defineGlobal("console", console);
+	defineGlobal("print", function print(...args) {
+		output("log", args.map(String).join(" "), ...args);
+	});
+})`
+
+// ConsoleHandler receives the output of `console` and `print` of the scripts.
+type ConsoleHandler interface {
+	// HandleConsole is called with the context.Context of the current call from Go, the level
+	// ("log", "info", "warn", "error" or "debug"), the message formatted from the args, and the
+	// args converted to golang values, which are nil if they can't be converted, such as the
+	// cyclic objects. It must not use the JsContext.
+	HandleConsole(goCtx context.Context, level string, msg string, args []interface{})
+}
+
+// ConsoleHandlerFunc is an adapter to use a func as ConsoleHandler.
+type ConsoleHandlerFunc func(goCtx context.Context, level string, msg string, args []interface{})
+
+func (f ConsoleHandlerFunc) HandleConsole(goCtx context.Context, level string, msg string, args []interface{}) {
+	f(goCtx, level, msg, args)
+}
+
+// the handlers only using the messages, which the args are not converted for.
+type consoleArgsIgnored interface {
+	ignoresConsoleArgs()
+}
+
+// writes the messages of "warn" and "error" to stderr, and the others to stdout.
+type writerConsole struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (w writerConsole) ignoresConsoleArgs() {}
+
+func (w writerConsole) HandleConsole(_ context.Context, level string, msg string, _ []interface{}) {
+	out := w.stdout
+	if level == "warn" || level == "error" {
+		out = w.stderr
+	}
+	io.WriteString(out, msg + "\n")
+}
+
+// SetConsole replaces `console` and `print` with the ones calling handler, and `console` gets
+// `info`, `warn`, `error` and `debug` besides `log`. The exceptions of the jobs run while a module
+// is awaited are passed to handler at level "error" too. If handler is nil, the output is written
+// to the stdout and stderr of the process.
+func (ctx *JsContext) SetConsole(handler ConsoleHandler) (err error) {
+	if handler == nil {
+		handler = writerConsole{stdout: os.Stdout, stderr: os.Stderr}
+	}
+
+	ctx.lock()
+	defer ctx.unlock()
+
+	ctx.console = handler
+	if ctx.consoleSet {
+		return
+	}
+	jsCtx := ctx.jsContext // the handle must not be kept by the func
+	output := func(info *CallInfo, level string, msg string) {
+		handler := jsCtx.console
+		var args []interface{}
+		if _, ok := handler.(consoleArgsIgnored); !ok {
+			// the args following level and msg are converted only when they are used
+			args = make([]interface{}, len(info.Args)-2)
+			for i, arg := range info.Args[2:] {
+				args[i], _ = fromJsValue(jsCtx.c, arg.v)
+			}
+		}
+		handler.HandleConsole(info.Context, level, msg, args)
+	}
+	res, e := evalPrelude(ctx.c, "<console>", consolePrelude, output)
+	if e != nil {
+		err = e
+		return
+	}
+	C.JS_FreeValue(ctx.c, res)
+	ctx.consoleSet = true
+	return
+}
+
+// report an error not returned to Go, such as the exception thrown by a job run while a module
+// is awaited, to the console at level "error", or to stderr if the console is not set.
+func (ctx *jsContext) reportError(err error) {
+	handler := ctx.console
+	if handler == nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	var args []interface{}
+	if _, ok := handler.(consoleArgsIgnored); !ok {
+		args = []interface{}{err}
+	}
+	handler.HandleConsole(ctx.goCtx, "error", err.Error(), args)
+}
+
+// SetOutput is same as SetConsole, with the handler writing the messages of `console.warn` and
+// `console.error` to stderr, and the others to stdout. A nil writer means the one of the process.
+func (ctx *JsContext) SetOutput(stdout, stderr io.Writer) error {
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	return ctx.SetConsole(writerConsole{stdout: stdout, stderr: stderr})
+}
@@ -0,0 +1,173 @@
+package quickjs
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+type consoleRecord struct {
+	level string
+	msg   string
+	args  []interface{}
+}
+
+// a context with the console output recorded.
+func newConsoleContext(t *testing.T) (*JsContext, *[]consoleRecord) {
+	t.Helper()
+	ctx := newTestContext(t)
+	records := &[]consoleRecord{}
+	err := ctx.SetConsole(ConsoleHandlerFunc(func(_ context.Context, level string, msg string, args []interface{}) {
+		*records = append(*records, consoleRecord{level, msg, args})
+	}))
+	if err != nil {
+		t.Fatalf("SetConsole: %v", err)
+	}
+	return ctx, records
+}
+
+func TestConsoleFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   consoleRecord
+	}{
+		{"strings", `console.log("a", "b")`, consoleRecord{"log", "a b", []interface{}{"a", "b"}}},
+		{"string format", `console.info("%s=%s", "k", "v")`, consoleRecord{"info", "k=v", []interface{}{"%s=%s", "k", "v"}}},
+		{"integer format", `console.warn("%d %i", 1.5, "2.5")`, consoleRecord{"warn", "1.5 2", []interface{}{"%d %i", 1.5, "2.5"}}},
+		{"float format", `console.error("%f", "1.25")`, consoleRecord{"error", "1.25", []interface{}{"%f", "1.25"}}},
+		{"object format", `console.debug("%o", {a: 1})`, consoleRecord{"debug", `{"a":1}`, []interface{}{"%o", map[string]interface{}{"a": int64(1)}}}},
+		{"percent", `console.log("100%%", 1)`, consoleRecord{"log", "100% 1", []interface{}{"100%%", int64(1)}}},
+		{"missing args", `console.log("%s and %s", "a")`, consoleRecord{"log", "a and %s", []interface{}{"%s and %s", "a"}}},
+		{"css", `console.log("%cred", "color: red")`, consoleRecord{"log", "red", []interface{}{"%cred", "color: red"}}},
+		{"values", `console.log(1, null, undefined, true, 2n, [1, "a"])`,
+			consoleRecord{"log", `1 null undefined true 2n [1,"a"]`, []interface{}{int64(1), nil, nil, true, nil, []interface{}{int64(1), "a"}}}},
+		{"function", `console.log(function f() {})`, consoleRecord{"log", "[Function: f]", nil}},
+		{"print", `print("a", 1, {b: 2})`, consoleRecord{"log", "a 1 [object Object]", []interface{}{"a", int64(1), map[string]interface{}{"b": int64(2)}}}},
+		{"cyclic", `const a = {}; a.self = a; console.log(a)`, consoleRecord{"log", "[object Object]", []interface{}{nil}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, records := newConsoleContext(t)
+			if _, err := ctx.Eval(tt.script, nil); err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			if len(*records) != 1 {
+				t.Fatalf("got %d records", len(*records))
+			}
+			got := (*records)[0]
+			if got.level != tt.want.level || got.msg != tt.want.msg {
+				t.Errorf("got %q %q, want %q %q", got.level, got.msg, tt.want.level, tt.want.msg)
+			}
+			if tt.want.args != nil && !reflect.DeepEqual(got.args, tt.want.args) {
+				t.Errorf("got args %#v, want %#v", got.args, tt.want.args)
+			}
+		})
+	}
+}
+
+func TestConsoleError(t *testing.T) {
+	ctx, records := newConsoleContext(t)
+	if _, err := ctx.Eval(`console.error(new TypeError("bad"))`, nil); err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if msg := (*records)[0].msg; !bytes.HasPrefix([]byte(msg), []byte("TypeError: bad\n    at ")) {
+		t.Errorf("got %q", msg)
+	}
+}
+
+func TestConsoleContext(t *testing.T) {
+	type key struct{}
+	ctx := newTestContext(t)
+	var got interface{}
+	ctx.SetConsole(ConsoleHandlerFunc(func(goCtx context.Context, _ string, _ string, _ []interface{}) {
+		got = goCtx.Value(key{})
+	}))
+	goCtx := context.WithValue(context.Background(), key{}, "value")
+	if _, err := ctx.EvalContext(goCtx, `console.log(1)`, nil); err != nil {
+		t.Fatalf("EvalContext: %v", err)
+	}
+	if got != "value" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestSetOutput(t *testing.T) {
+	ctx := newTestContext(t)
+	var stdout, stderr bytes.Buffer
+	if err := ctx.SetOutput(&stdout, &stderr); err != nil {
+		t.Fatalf("SetOutput: %v", err)
+	}
+	script := `
+		const a = {};
+		a.self = a;
+		console.log("log", a);
+		console.info("info");
+		console.debug("debug");
+		print("print");
+		console.warn("warn");
+		console.error("error");
+	`
+	if _, err := ctx.Eval(script, nil); err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if got, want := stdout.String(), "log [object Object]\ninfo\ndebug\nprint\n"; got != want {
+		t.Errorf("got stdout %q, want %q", got, want)
+	}
+	if got, want := stderr.String(), "warn\nerror\n"; got != want {
+		t.Errorf("got stderr %q, want %q", got, want)
+	}
+}
+
+func TestReplaceConsole(t *testing.T) {
+	ctx := newTestContext(t)
+	var first, second bytes.Buffer
+	ctx.SetOutput(&first, nil)
+	ctx.Eval(`console.log(1)`, nil)
+	ctx.SetOutput(&second, nil)
+	ctx.Eval(`console.log(2)`, nil)
+	if first.String() != "1\n" || second.String() != "2\n" {
+		t.Errorf("got %q and %q", first.String(), second.String())
+	}
+}
+
+// the exceptions of the jobs not awaited by a module are reported to the console, instead of stderr.
+func TestConsoleJobErrors(t *testing.T) {
+	const script = `
+		export const started = true;
+		queueMicrotask(() => { throw new Error("job failed"); });
+		await new Promise(r => setTimeout(r, 1));
+	`
+	evals := []struct {
+		name string
+		eval func(ctx *JsContext) error
+	}{
+		{"Eval", func(ctx *JsContext) error {
+			_, err := ctx.Eval(script, nil)
+			return err
+		}},
+		{"EvalModule", func(ctx *JsContext) error {
+			_, err := ctx.EvalModule("m.js", script)
+			return err
+		}},
+	}
+	for _, tt := range evals {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, records := newConsoleContext(t)
+			if err := tt.eval(ctx); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if len(*records) != 1 {
+				t.Fatalf("got %d records", len(*records))
+			}
+			got := (*records)[0]
+			if got.level != "error" || !bytes.Contains([]byte(got.msg), []byte("job failed")) {
+				t.Errorf("got %q %q", got.level, got.msg)
+			}
+			if len(got.args) != 1 || got.args[0].(error).Error() != got.msg {
+				t.Errorf("got args %#v", got.args)
+			}
+		})
+	}
+}
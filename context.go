@@ -2,13 +2,18 @@ package quickjs
 
 /*
 #include "quickjs-libc.h"
+#include "go-proxy.h"
 static int getValTag(JSValueConst v) {
 	return JS_VALUE_GET_TAG(v);
 }
 int registerGoObjectClass(JSRuntime *rt);
+extern char *goModuleNormalize(JSContext *ctx, char *module_base_name, char *module_name, void *opaque);
+extern JSModuleDef *goModuleLoader(JSContext *ctx, char *module_name, void *opaque);
 */
 import "C"
 import (
+	"context"
+	"io/fs"
 	"reflect"
 	"unsafe"
 	"fmt"
@@ -20,16 +25,49 @@ const noname = ""
 
 var (
 	globalMu = &sync.Mutex{}
+
+	ctxStoreMu = &sync.Mutex{}
+	ctxStore = make(map[uintptr]*jsContext)
 )
 
 type JsRuntime struct {
 	rt *C.JSRuntime
 }
 
+// JsContext is the handle returned to the users. The state it points to
+// can be found by the C context in callbacks, and it will not keep the
+// handle alive, so the context is still freed when the handle is collected.
 type JsContext struct {
+	*jsContext
+	inCallback bool // injected to a golang func called by JS, the mutex is already locked
+}
+
+type jsContext struct {
 	rt *JsRuntime
 	c *C.JSContext
 	mu *sync.Mutex
+	classes map[reflect.Type]*goClass
+	modules map[string]*goModule
+	loader ModuleLoader
+	importMap *ImportMap
+	importMetaHook ImportMetaHook
+	dataModules map[string]C.JSValue // JSON, text and bytes modules loaded but not initialized
+	deps []string // the names of the modules loaded from the files or the loader
+	held map[uint32]C.JSValue
+	heldSeq uint32
+	unheldMu sync.Mutex
+	unheld []uint32 // ids of the held values to release, whose golang handles are collected
+	requireFS fs.FS // set by EnableRequire
+	goCtx context.Context // the context.Context of the current call from Go
+	autoRunJobs bool
+	tasks *taskQueue
+	timers map[int]*jsTimer
+	timerSeq int
+	clock Clock
+	channelFactory uint32 // id of the held func wrapping golang channels
+	streamFactory uint32 // id of the held func wrapping the values of Stream
+	console ConsoleHandler // set by SetConsole
+	consoleSet bool // console and print are replaced
 }
 
 func NewContext() (*JsContext, error) {
@@ -57,21 +95,84 @@ func NewContext() (*JsContext, error) {
 	runtime.SetFinalizer(r, freeJsRuntime)
 	loadPreludeModules(ctx)
 	c := &JsContext {
-		rt: r,
-		c: ctx,
-		mu: &sync.Mutex{},
+		jsContext: &jsContext {
+			rt: r,
+			c: ctx,
+			mu: &sync.Mutex{},
+			classes: make(map[reflect.Type]*goClass),
+			modules: make(map[string]*goModule),
+			dataModules: make(map[string]C.JSValue),
+			held: make(map[uint32]C.JSValue),
+			goCtx: context.Background(),
+			autoRunJobs: true,
+			tasks: newTaskQueue(),
+			timers: make(map[int]*jsTimer),
+			clock: realClock{},
+		},
 	}
+	saveJsContext(c.jsContext)
 	runtime.SetFinalizer(c, freeJsContext)
+	if err := c.addTimerFuncs(); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
 
+func saveJsContext(ctx *jsContext) {
+	ctxStoreMu.Lock()
+	defer ctxStoreMu.Unlock()
+	ctxStore[uintptr(unsafe.Pointer(ctx.c))] = ctx
+}
+
+func getJsContext(c *C.JSContext) *jsContext {
+	ctxStoreMu.Lock()
+	defer ctxStoreMu.Unlock()
+	return ctxStore[uintptr(unsafe.Pointer(c))]
+}
+
+func delJsContext(c *C.JSContext) {
+	ctxStoreMu.Lock()
+	defer ctxStoreMu.Unlock()
+	delete(ctxStore, uintptr(unsafe.Pointer(c)))
+}
+
+// the goroutine holding the context is locked to its thread, and the stack top of the runtime
+// is updated, for the stack of the thread is checked by quickjs.
+func (ctx *JsContext) lock() {
+	if !ctx.inCallback {
+		ctx.mu.Lock()
+		runtime.LockOSThread()
+		C.JS_UpdateStackTop(ctx.rt.rt)
+		ctx.releaseUnheld()
+	}
+}
+
+func (ctx *JsContext) unlock() {
+	if !ctx.inCallback {
+		runtime.UnlockOSThread()
+		ctx.mu.Unlock()
+	}
+}
+
+// set the context.Context of the current call, the returned func restores the previous one.
+func (ctx *JsContext) withGoContext(goCtx context.Context) (restore func()) {
+	if goCtx == nil {
+		goCtx = context.Background()
+	}
+	prev := ctx.goCtx
+	ctx.goCtx = goCtx
+	return func() {
+		ctx.goCtx = prev
+	}
+}
+
 func createCustomerContext(rt *C.JSRuntime) *C.JSContext {
 	C.js_std_init_handlers(rt)
 	ctx := C.JS_NewContext(rt)
 	if ctx == (*C.JSContext)(unsafe.Pointer(nil)) {
 		return ctx
 	}
-	C.JS_SetModuleLoaderFunc(rt, (*C.JSModuleNormalizeFunc)(unsafe.Pointer(nil)), (*C.JSModuleLoaderFunc)(C.js_module_loader), unsafe.Pointer(nil))
+	C.JS_SetModuleLoaderFunc(rt, (*C.JSModuleNormalizeFunc)(C.goModuleNormalize), (*C.JSModuleLoaderFunc)(C.goModuleLoader), unsafe.Pointer(nil))
 	return ctx
 }
 
@@ -81,11 +182,82 @@ func freeJsRuntime(rt *JsRuntime) {
 }
 
 func freeJsContext(ctx *JsContext) {
-	fmt.Printf("--- context freed\n")
+	ctx.free()
+}
+
+// Close frees the context at once instead of waiting for the garbage collector,
+// it must not be used any more. Closing the handle injected to a golang func
+// does nothing.
+func (ctx *JsContext) Close() {
+	if ctx.inCallback {
+		return
+	}
+	ctx.lock()
+	defer ctx.unlock()
+	if ctx.c == nil {
+		return
+	}
+	runtime.SetFinalizer(ctx, nil)
+	ctx.free()
+}
+
+func (ctx *jsContext) free() {
 	c := ctx.c
+	delJsContext(c)
+	freeGoClasses(ctx)
+	for _, t := range ctx.timers {
+		t.timer.Stop()
+	}
+	ctx.timers = nil
+	for _, v := range ctx.dataModules {
+		C.JS_FreeValue(c, v)
+	}
+	for _, v := range ctx.held {
+		C.JS_FreeValue(c, v)
+	}
+	ctx.dataModules, ctx.held = nil, nil
 	delPtrStore((uintptr(unsafe.Pointer(c))))
 
 	C.JS_FreeContext(c)
+	ctx.c = nil
+}
+
+// keep a JS value referenced by a golang value, which is freed by release()
+// or when the context is freed.
+func (ctx *jsContext) hold(v C.JSValue) (id uint32) {
+	ctx.heldSeq += 1
+	id = ctx.heldSeq
+	ctx.held[id] = v
+	return
+}
+
+func (ctx *jsContext) release(id uint32) {
+	if v, ok := ctx.held[id]; ok {
+		delete(ctx.held, id)
+		C.JS_FreeValue(ctx.c, v)
+	}
+}
+
+// release the held value later by the thread owning the context, it can be called in any
+// goroutine, such as the finalizers, which must not wait for the context.
+func (ctx *jsContext) releaseLater(id uint32) {
+	ctx.unheldMu.Lock()
+	defer ctx.unheldMu.Unlock()
+	ctx.unheld = append(ctx.unheld, id)
+}
+
+func (ctx *jsContext) releaseUnheld() {
+	ctx.unheldMu.Lock()
+	ids := ctx.unheld
+	ctx.unheld = nil
+	ctx.unheldMu.Unlock()
+
+	if ctx.c == nil {
+		return
+	}
+	for _, id := range ids {
+		ctx.release(id)
+	}
 }
 
 func loadPreludeModules(ctx *C.JSContext) {
@@ -102,8 +274,15 @@ func loadPreludeModules(ctx *C.JSContext) {
 }
 
 func (ctx *JsContext) Eval(script string, env map[string]interface{}) (res interface{}, err error) {
-	ctx.mu.Lock()
-	defer ctx.mu.Unlock()
+	return ctx.EvalContext(context.Background(), script, env)
+}
+
+// EvalContext is same as Eval, goCtx is passed to the golang funcs called by the script,
+// which take a leading context.Context or *CallInfo.
+func (ctx *JsContext) EvalContext(goCtx context.Context, script string, env map[string]interface{}) (res interface{}, err error) {
+	ctx.lock()
+	defer ctx.unlock()
+	defer ctx.withGoContext(goCtx)()
 
 	cstr := C.CString(script)
 	length := len(script)
@@ -113,8 +292,14 @@ func (ctx *JsContext) Eval(script string, env map[string]interface{}) (res inter
 }
 
 func (ctx *JsContext) EvalFile(scriptFile string, env map[string]interface{}) (res interface{}, err error) {
-	ctx.mu.Lock()
-	defer ctx.mu.Unlock()
+	return ctx.EvalFileContext(context.Background(), scriptFile, env)
+}
+
+// EvalFileContext is same as EvalFile, goCtx is used as that of EvalContext.
+func (ctx *JsContext) EvalFileContext(goCtx context.Context, scriptFile string, env map[string]interface{}) (res interface{}, err error) {
+	ctx.lock()
+	defer ctx.unlock()
+	defer ctx.withGoContext(goCtx)()
 
 	var scriptClen C.size_t
 
@@ -131,6 +316,18 @@ func (ctx *JsContext) EvalFile(scriptFile string, env map[string]interface{}) (r
 }
 
 func (ctx *JsContext) eval(scriptCstr *C.char, scriptClen C.size_t, filename string, env map[string]interface{}) (res interface{}, err error) {
+	jsVal, e := ctx.evalValue(scriptCstr, scriptClen, filename, env)
+	if e != nil {
+		err = e
+		return
+	}
+	res, err = fromJsValue(ctx.c, jsVal)
+	C.JS_FreeValue(ctx.c, jsVal)
+	return
+}
+
+// the result must be freed by the caller if err is nil.
+func (ctx *JsContext) evalValue(scriptCstr *C.char, scriptClen C.size_t, filename string, env map[string]interface{}) (res C.JSValue, err error) {
 	if err = ctx.setEnv(env); err != nil {
 		return
 	}
@@ -145,19 +342,28 @@ func (ctx *JsContext) eval(scriptCstr *C.char, scriptClen C.size_t, filename str
 		jsVal = C.JS_Eval(c, scriptCstr, scriptClen, scriptFileCstr, C.JS_EVAL_TYPE_MODULE | C.JS_EVAL_FLAG_COMPILE_ONLY)
 		if C.JS_IsException(jsVal) == 0 {
 			C.js_module_set_import_meta(c, jsVal, 1, 1)
-			jsVal = C.JS_EvalFunction(c, jsVal)
+			if setImportMeta(c, C.moduleOf(jsVal)) != 0 {
+				C.JS_FreeValue(c, jsVal)
+				jsVal = C.toException()
+			} else {
+				// the timers and async golang funcs awaited at the top level are run by the Go loop
+				if jsVal, err = ctx.awaitModule(C.JS_EvalFunction(c, jsVal)); err != nil {
+					return
+				}
+			}
 		}
-		jsVal = C.js_std_await(c, jsVal);
 	} else {
 		jsVal = C.JS_Eval(c, scriptCstr, scriptClen, scriptFileCstr, C.JS_EVAL_TYPE_GLOBAL)
 	}
 	if (C.JS_IsException(jsVal) != 0) {
-		C.js_std_dump_error(c);
-		err = fmt.Errorf("exception thrown")
-	} else {
-		res, err = fromJsValue(c, jsVal)
+		err = fromJsException(c)
+		return
 	}
-	C.JS_FreeValue(c, jsVal)
+	if err = ctx.autoRunPendingJobs(); err != nil {
+		C.JS_FreeValue(c, jsVal)
+		return
+	}
+	res = jsVal
 	return
 }
 
@@ -196,8 +402,8 @@ func getVar(c *C.JSContext, global C.JSValue, name string) (v C.JSValue, err err
 }
 
 func (ctx *JsContext) GetGlobal(name string) (res interface{}, err error) {
-	ctx.mu.Lock()
-	defer ctx.mu.Unlock()
+	ctx.lock()
+	defer ctx.unlock()
 
 	c := ctx.c
 
@@ -220,9 +426,28 @@ func (ctx *JsContext) getVar(name string) (C.JSValue, error) {
 }
 
 func (ctx *JsContext) CallFunc(funcName string, args ...interface{}) (res interface{}, err error) {
-	ctx.mu.Lock()
-	defer ctx.mu.Unlock()
+	return ctx.CallFuncContext(context.Background(), funcName, args...)
+}
+
+// CallFuncContext is same as CallFunc, goCtx is used as that of EvalContext.
+func (ctx *JsContext) CallFuncContext(goCtx context.Context, funcName string, args ...interface{}) (res interface{}, err error) {
+	ctx.lock()
+	defer ctx.unlock()
+	defer ctx.withGoContext(goCtx)()
+
+	r, e := ctx.callFuncValue(funcName, args...)
+	if e != nil {
+		err = e
+		return
+	}
+	defer C.JS_FreeValue(ctx.c, r)
 
+	res, err = fromJsValue(ctx.c, r)
+	return
+}
+
+// the result must be freed by the caller if err is nil.
+func (ctx *JsContext) callFuncValue(funcName string, args ...interface{}) (res C.JSValue, err error) {
 	c := ctx.c
 
 	v, e := ctx.getVar(funcName)
@@ -241,9 +466,15 @@ func (ctx *JsContext) CallFunc(funcName string, args ...interface{}) (res interf
 		err = e
 		return
 	}
-	defer C.JS_FreeValue(c, r)
-
-	res, err = fromJsValue(c, r)
+	if C.JS_IsException(r) != 0 {
+		err = fromJsException(c)
+		return
+	}
+	if err = ctx.autoRunPendingJobs(); err != nil {
+		C.JS_FreeValue(c, r)
+		return
+	}
+	res = r
 	return
 }
 
@@ -261,8 +492,8 @@ func (ctx *JsContext) BindFunc(funcName string, funcVarPtr interface{}) (err err
 		return
 	}
 
-	ctx.mu.Lock()
-	defer ctx.mu.Unlock()
+	ctx.lock()
+	defer ctx.unlock()
 
 	c := ctx.c
 
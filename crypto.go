@@ -0,0 +1,313 @@
+package quickjs
+
+/*
+#include "quickjs.h"
+*/
+import "C"
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"fmt"
+)
+
+const cryptoPrelude = `(function(random, randomUUID, digest, hmacSign, hmacVerify, aesGCM) {
+	const hashNames = ["SHA-1", "SHA-256", "SHA-384", "SHA-512"];
+	const keyUsages = {"HMAC": ["sign", "verify"], "AES-GCM": ["encrypt", "decrypt", "wrapKey", "unwrapKey"]};
+
+	// the algorithm is normalized to {name, ...}, with the name in upper case
+	const normalizeAlgorithm = (algorithm, names) => {
+		if (typeof algorithm === "string") {
+			algorithm = {name: algorithm};
+		}
+		if (algorithm == null || typeof algorithm.name !== "string") {
+			throw new TypeError("algorithm name expected");
+		}
+		const name = algorithm.name.toUpperCase();
+		if (!names.includes(name)) {
+			throw new DOMException("unrecognized algorithm name: " + algorithm.name, "NotSupportedError");
+		}
+		return {...algorithm, name};
+	};
+	const toBytes = data => {
+		if (data instanceof ArrayBuffer || ArrayBuffer.isView(data)) {
+			return data;
+		}
+		throw new TypeError("data must be an ArrayBuffer or a view of it");
+	};
+	// the errors of the golang funcs are thrown as DOMException
+	const operate = (fn, ...args) => {
+		try {
+			return fn(...args);
+		} catch (e) {
+			throw new DOMException(e.message, "OperationError");
+		}
+	};
+
+	const keyToken = Symbol("CryptoKey");
+	let keyData;
+	class CryptoKey {
+		#data;
+		#algorithm;
+		#extractable;
+		#usages;
+		constructor(token, data, algorithm, extractable, usages) {
+			if (token !== keyToken) {
+				throw new TypeError("illegal constructor");
+			}
+			this.#data = data;
+			this.#algorithm = algorithm;
+			this.#extractable = extractable;
+			this.#usages = usages;
+		}
+		static {
+			keyData = (key, name, usage) => {
+				if (!(#data in key)) {
+					throw new TypeError("key must be a CryptoKey");
+				}
+				if (name !== undefined && key.#algorithm.name !== name) {
+					throw new DOMException("key is not for " + name, "InvalidAccessError");
+				}
+				if (usage !== undefined && !key.#usages.includes(usage)) {
+					throw new DOMException("key cannot be used to " + usage, "InvalidAccessError");
+				}
+				return key.#data;
+			};
+		}
+		get type() {
+			return "secret";
+		}
+		get extractable() {
+			return this.#extractable;
+		}
+		get algorithm() {
+			return structuredCloneAlgorithm(this.#algorithm);
+		}
+		get usages() {
+			return [...this.#usages];
+		}
+		get [Symbol.toStringTag]() {
+			return "CryptoKey";
+		}
+	}
+	const structuredCloneAlgorithm = algorithm => algorithm.hash ? {...algorithm, hash: {...algorithm.hash}} : {...algorithm};
+
+	const subtle = {
+		async digest(algorithm, data) {
+			algorithm = normalizeAlgorithm(algorithm, hashNames);
+			return operate(digest, algorithm.name, toBytes(data)).buffer;
+		},
+		async importKey(format, data, algorithm, extractable, usages) {
+			if (format !== "raw") {
+				throw new DOMException("unsupported key format: " + format, "NotSupportedError");
+			}
+			algorithm = normalizeAlgorithm(algorithm, Object.keys(keyUsages));
+			usages = [...usages];
+			for (const usage of usages) {
+				if (!keyUsages[algorithm.name].includes(usage)) {
+					throw new DOMException("invalid key usage: " + usage, "SyntaxError");
+				}
+			}
+			if (usages.length === 0) {
+				throw new DOMException("key usages must not be empty", "SyntaxError");
+			}
+			const bytes = toBytes(data);
+			const key = new Uint8Array(bytes instanceof ArrayBuffer ? bytes.slice(0) : bytes.buffer.slice(bytes.byteOffset, bytes.byteOffset + bytes.byteLength));
+			if (algorithm.name === "HMAC") {
+				const hash = normalizeAlgorithm(algorithm.hash, hashNames);
+				if (key.length === 0) {
+					throw new DOMException("key must not be empty", "DataError");
+				}
+				algorithm = {name: "HMAC", hash: {name: hash.name}, length: key.length * 8};
+			} else {
+				if (![16, 24, 32].includes(key.length)) {
+					throw new DOMException("AES key must be 128, 192 or 256 bits", "DataError");
+				}
+				algorithm = {name: "AES-GCM", length: key.length * 8};
+			}
+			return new CryptoKey(keyToken, key, algorithm, Boolean(extractable), usages);
+		},
+		async exportKey(format, key) {
+			if (format !== "raw") {
+				throw new DOMException("unsupported key format: " + format, "NotSupportedError");
+			}
+			const data = keyData(key);
+			if (!key.extractable) {
+				throw new DOMException("key is not extractable", "InvalidAccessError");
+			}
+			return data.buffer.slice(0);
+		},
+		async sign(algorithm, key, data) {
+			algorithm = normalizeAlgorithm(algorithm, ["HMAC"]);
+			const k = keyData(key, algorithm.name, "sign");
+			return operate(hmacSign, key.algorithm.hash.name, k, toBytes(data)).buffer;
+		},
+		async verify(algorithm, key, signature, data) {
+			algorithm = normalizeAlgorithm(algorithm, ["HMAC"]);
+			const k = keyData(key, algorithm.name, "verify");
+			return operate(hmacVerify, key.algorithm.hash.name, k, toBytes(signature), toBytes(data));
+		},
+		async encrypt(algorithm, key, data) {
+			algorithm = normalizeAlgorithm(algorithm, ["AES-GCM"]);
+			const k = keyData(key, algorithm.name, "encrypt");
+			return operate(aesGCM, true, k, toBytes(algorithm.iv), algorithm.additionalData ?? null, algorithm.tagLength ?? 128, toBytes(data)).buffer;
+		},
+		async decrypt(algorithm, key, data) {
+			algorithm = normalizeAlgorithm(algorithm, ["AES-GCM"]);
+			const k = keyData(key, algorithm.name, "decrypt");
+			return operate(aesGCM, false, k, toBytes(algorithm.iv), algorithm.additionalData ?? null, algorithm.tagLength ?? 128, toBytes(data)).buffer;
+		},
+		get [Symbol.toStringTag]() {
+			return "SubtleCrypto";
+		},
+	};
+
+	const integerArrays = [Int8Array, Uint8Array, Uint8ClampedArray, Int16Array, Uint16Array, Int32Array, Uint32Array, BigInt64Array, BigUint64Array];
+	const crypto = {
+		getRandomValues(array) {
+			if (!integerArrays.some(t => array instanceof t)) {
+				throw new DOMException("array must be an integer typed array", "TypeMismatchError");
+			}
+			if (array.byteLength > 65536) {
+				throw new DOMException("array is larger than 65536 bytes", "QuotaExceededError");
+			}
+			new Uint8Array(array.buffer, array.byteOffset, array.byteLength).set(random(array.byteLength));
+			return array;
+		},
+		randomUUID() {
+			return randomUUID();
+		},
+		subtle,
+		get [Symbol.toStringTag]() {
+			return "Crypto";
+		},
+	};
+
+	for (const [name, value] of Object.entries({crypto, CryptoKey})) {
+		Object.defineProperty(globalThis, name, {value, writable: true, configurable: true});
+	}
+})`
+
+// EnableCrypto adds the global object `crypto` with `getRandomValues()`, `randomUUID()` and
+// a subset of `crypto.subtle`, which are implemented with the crypto packages of Go: `digest()`
+// for SHA-1/256/384/512, `sign()` and `verify()` for HMAC, `encrypt()` and `decrypt()` for AES-GCM,
+// and `importKey()` and `exportKey()` for raw keys. The methods of `crypto.subtle` return Promises.
+func (ctx *JsContext) EnableCrypto() (err error) {
+	ctx.lock()
+	defer ctx.unlock()
+
+	c := ctx.c
+	if err = defineDOMException(c); err != nil {
+		return
+	}
+	res, e := evalPrelude(c, "<crypto>", cryptoPrelude, cryptoRandom, cryptoRandomUUID, cryptoDigest, cryptoHMACSign, cryptoHMACVerify, cryptoAESGCM)
+	if e != nil {
+		err = e
+		return
+	}
+	C.JS_FreeValue(c, res)
+	return
+}
+
+func cryptoRandom(n int) (Uint8Array, error) {
+	b := make(Uint8Array, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// a random UUID of version 4
+func cryptoRandomUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func newHash(name string) (func() hash.Hash, error) {
+	switch name {
+	case "SHA-1":
+		return sha1.New, nil
+	case "SHA-256":
+		return sha256.New, nil
+	case "SHA-384":
+		return sha512.New384, nil
+	case "SHA-512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported hash %s", name)
+	}
+}
+
+func cryptoDigest(name string, data []byte) (Uint8Array, error) {
+	h, err := newHash(name)
+	if err != nil {
+		return nil, err
+	}
+	d := h()
+	d.Write(data)
+	return d.Sum(nil), nil
+}
+
+func cryptoHMACSign(name string, key []byte, data []byte) (Uint8Array, error) {
+	h, err := newHash(name)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(h, key)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+func cryptoHMACVerify(name string, key []byte, signature []byte, data []byte) (bool, error) {
+	mac, err := cryptoHMACSign(name, key, data)
+	if err != nil {
+		return false, err
+	}
+	return hmac.Equal(mac, signature), nil
+}
+
+// encrypt or decrypt data with AES-GCM, the tag is appended to the ciphertext.
+func cryptoAESGCM(encrypt bool, key []byte, iv []byte, additionalData []byte, tagLength int, data []byte) (Uint8Array, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) == 0 {
+		return nil, fmt.Errorf("iv must not be empty")
+	}
+	if tagLength % 8 != 0 {
+		return nil, fmt.Errorf("invalid tag length %d", tagLength)
+	}
+
+	var aead cipher.AEAD
+	switch {
+	case len(iv) == 12:
+		aead, err = cipher.NewGCMWithTagSize(block, tagLength/8)
+	case tagLength == 128:
+		aead, err = cipher.NewGCMWithNonceSize(block, len(iv))
+	default:
+		err = fmt.Errorf("tag length %d is not supported with an iv of %d bytes", tagLength, len(iv))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if encrypt {
+		return aead.Seal(nil, iv, data, additionalData), nil
+	}
+	plain, err := aead.Open(nil, iv, data, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
@@ -0,0 +1,174 @@
+package quickjs
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const testCryptoHelpers = `
+	const hex = b => Array.from(new Uint8Array(b), x => x.toString(16).padStart(2, "0")).join("");
+	const bytes = s => new TextEncoder().encode(s);
+	const key = (raw, alg, usages) => crypto.subtle.importKey("raw", bytes(raw), alg, true, usages);
+`
+
+// the AES-GCM result of Go for the same key, nonce, additional data and plaintext.
+func testAESGCM(t *testing.T, key, nonce, ad, plaintext string) string {
+	t.Helper()
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(gcm.Seal(nil, []byte(nonce), []byte(plaintext), []byte(ad)))
+}
+
+func TestCryptoSubtle(t *testing.T) {
+	gcm := testAESGCM(t, "0123456789abcdef", "nonce-12byte", "ad", "secret")
+	tests := []struct {
+		name    string
+		script  string
+		want    interface{}
+		wantErr string
+	}{
+		{name: "digest sha-256", script: `crypto.subtle.digest("SHA-256", bytes("abc")).then(hex)`,
+			want: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{name: "digest sha-1", script: `crypto.subtle.digest({name: "sha-1"}, bytes("abc").buffer).then(hex)`,
+			want: "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{name: "digest sha-512 length", script: `crypto.subtle.digest("SHA-512", new Uint8Array()).then(b => b.byteLength)`,
+			want: int64(64)},
+		{name: "digest unknown", script: `crypto.subtle.digest("MD5", bytes("abc"))`, wantErr: "NotSupportedError"},
+		{name: "digest bad data", script: `crypto.subtle.digest("SHA-256", "abc")`, wantErr: "TypeError"},
+		{name: "hmac sign", script: `
+			key("key", {name: "HMAC", hash: "SHA-256"}, ["sign"])
+				.then(k => crypto.subtle.sign("HMAC", k, bytes("The quick brown fox jumps over the lazy dog")))
+				.then(hex)`,
+			want: "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{name: "hmac verify", script: `(async () => {
+				const k = await key("key", {name: "HMAC", hash: "SHA-256"}, ["sign", "verify"]);
+				const sig = await crypto.subtle.sign("HMAC", k, bytes("data"));
+				return [await crypto.subtle.verify("HMAC", k, sig, bytes("data")), await crypto.subtle.verify("HMAC", k, sig, bytes("date"))];
+			})()`, want: []interface{}{true, false}},
+		{name: "hmac key algorithm", script: `key("key", {name: "hmac", hash: {name: "SHA-1"}}, ["sign"]).then(k => [k.type, k.algorithm, k.usages])`,
+			want: []interface{}{"secret", map[string]interface{}{"name": "HMAC", "hash": map[string]interface{}{"name": "SHA-1"}, "length": int64(24)},
+				[]interface{}{"sign"}}},
+		{name: "hmac usage denied", script: `
+			key("key", {name: "HMAC", hash: "SHA-256"}, ["verify"]).then(k => crypto.subtle.sign("HMAC", k, bytes("data")))`,
+			wantErr: "InvalidAccessError"},
+		{name: "aes-gcm encrypt", script: `
+			key("0123456789abcdef", "AES-GCM", ["encrypt"])
+				.then(k => crypto.subtle.encrypt({name: "AES-GCM", iv: bytes("nonce-12byte"), additionalData: bytes("ad")}, k, bytes("secret")))
+				.then(hex)`,
+			want: gcm},
+		{name: "aes-gcm round trip", script: `(async () => {
+				const k = await key("0123456789abcdef0123456789abcdef", "AES-GCM", ["encrypt", "decrypt"]);
+				const iv = crypto.getRandomValues(new Uint8Array(12));
+				const data = await crypto.subtle.encrypt({name: "AES-GCM", iv}, k, bytes("secret"));
+				return new TextDecoder().decode(await crypto.subtle.decrypt({name: "AES-GCM", iv}, k, data));
+			})()`, want: "secret"},
+		{name: "aes-gcm tampered", script: `(async () => {
+				const k = await key("0123456789abcdef", "AES-GCM", ["encrypt", "decrypt"]);
+				const iv = new Uint8Array(12);
+				const data = new Uint8Array(await crypto.subtle.encrypt({name: "AES-GCM", iv}, k, bytes("secret")));
+				data[0] ^= 1;
+				return crypto.subtle.decrypt({name: "AES-GCM", iv}, k, data);
+			})()`, wantErr: "OperationError"},
+		{name: "aes bad key size", script: `key("short", "AES-GCM", ["encrypt"])`, wantErr: "DataError"},
+		{name: "export key", script: `key("key", {name: "HMAC", hash: "SHA-256"}, ["sign"]).then(k => crypto.subtle.exportKey("raw", k)).then(hex)`,
+			want: "6b6579"},
+		{name: "export not extractable", script: `
+			crypto.subtle.importKey("raw", bytes("key"), {name: "HMAC", hash: "SHA-256"}, false, ["sign"])
+				.then(k => crypto.subtle.exportKey("raw", k))`, wantErr: "InvalidAccessError"},
+		{name: "unsupported format", script: `crypto.subtle.importKey("jwk", {}, "AES-GCM", true, ["encrypt"])`, wantErr: "NotSupportedError"},
+		{name: "illegal constructor", script: `new CryptoKey()`, wantErr: "TypeError"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			if err := ctx.EnableWebGlobals(); err != nil {
+				t.Fatalf("EnableWebGlobals: %v", err)
+			}
+			if err := ctx.EnableCrypto(); err != nil {
+				t.Fatalf("EnableCrypto: %v", err)
+			}
+			if _, err := ctx.Eval(testCryptoHelpers, nil); err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			res, err := ctx.EvalAwait(context.Background(), tt.script, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EvalAwait: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestCryptoRandom(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		want    interface{}
+		wantErr string
+	}{
+		{name: "filled", script: `crypto.getRandomValues(new Uint8Array(64)).some(b => b !== 0)`, want: true},
+		{name: "same array", script: `const a = new Uint32Array(4); crypto.getRandomValues(a) === a`, want: true},
+		{name: "view", script: `
+			const a = new Uint8Array(8);
+			crypto.getRandomValues(new Uint8Array(a.buffer, 2, 4));
+			[a[0], a[1], a[6], a[7]]`, want: []interface{}{int64(0), int64(0), int64(0), int64(0)}},
+		{name: "bigint", script: `typeof crypto.getRandomValues(new BigUint64Array(1))[0]`, want: "bigint"},
+		{name: "float array", script: `crypto.getRandomValues(new Float64Array(1))`, wantErr: "TypeMismatchError"},
+		{name: "too large", script: `crypto.getRandomValues(new Uint8Array(65537))`, wantErr: "QuotaExceededError"},
+		{name: "uuids differ", script: `crypto.randomUUID() !== crypto.randomUUID()`, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			if err := ctx.EnableCrypto(); err != nil {
+				t.Fatalf("EnableCrypto: %v", err)
+			}
+			res, err := ctx.Eval(tt.script, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+
+	ctx := newTestContext(t)
+	if err := ctx.EnableCrypto(); err != nil {
+		t.Fatalf("EnableCrypto: %v", err)
+	}
+	uuid, err := ctx.Eval(`crypto.randomUUID()`, nil)
+	if err != nil {
+		t.Fatalf("randomUUID: %v", err)
+	}
+	v4 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if s, ok := uuid.(string); !ok || !v4.MatchString(s) {
+		t.Errorf("got %#v, want a UUID v4", uuid)
+	}
+}
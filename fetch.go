@@ -0,0 +1,431 @@
+package quickjs
+
+/*
+#include "quickjs.h"
+*/
+import "C"
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"fmt"
+)
+
+const fetchPrelude = `(function(roundTrip, encode, decode) {
+	const isHeaderName = name => /^[!#$%&'*+\-.^_` + "`" + `|~0-9A-Za-z]+$/.test(name);
+	const normalizeValue = value => String(value).replace(/^[\t\n\r ]+|[\t\n\r ]+$/g, "");
+	const checkName = name => {
+		name = String(name);
+		if (!isHeaderName(name)) {
+			throw new TypeError("invalid header name: " + name);
+		}
+		return name.toLowerCase();
+	};
+
+	class Headers {
+		#list = []; // [lower-cased name, value]
+		constructor(init) {
+			if (init == null) {
+				return;
+			}
+			if (typeof init !== "object") {
+				throw new TypeError("headers init must be an object");
+			}
+			if (typeof init[Symbol.iterator] === "function") {
+				for (const pair of init) {
+					const p = [...pair];
+					if (p.length !== 2) {
+						throw new TypeError("header pair must have exactly 2 items");
+					}
+					this.append(p[0], p[1]);
+				}
+				return;
+			}
+			for (const name of Object.keys(init)) {
+				this.append(name, init[name]);
+			}
+		}
+		append(name, value) {
+			this.#list.push([checkName(name), normalizeValue(value)]);
+		}
+		delete(name) {
+			name = checkName(name);
+			this.#list = this.#list.filter(([n]) => n !== name);
+		}
+		get(name) {
+			name = checkName(name);
+			const values = this.#list.filter(([n]) => n === name).map(([, v]) => v);
+			return values.length > 0 ? values.join(", ") : null;
+		}
+		getSetCookie() {
+			return this.#list.filter(([n]) => n === "set-cookie").map(([, v]) => v);
+		}
+		has(name) {
+			name = checkName(name);
+			return this.#list.some(([n]) => n === name);
+		}
+		set(name, value) {
+			name = checkName(name);
+			value = normalizeValue(value);
+			const i = this.#list.findIndex(([n]) => n === name);
+			if (i < 0) {
+				this.#list.push([name, value]);
+				return;
+			}
+			this.#list[i] = [name, value];
+			this.#list = this.#list.filter(([n], j) => n !== name || j <= i);
+		}
+		forEach(callback, thisArg) {
+			for (const [name, value] of this) {
+				callback.call(thisArg, value, name, this);
+			}
+		}
+		*entries() {
+			// sorted by name, the values of a name are combined except set-cookie
+			const names = [...new Set(this.#list.map(([n]) => n))].sort();
+			for (const name of names) {
+				if (name === "set-cookie") {
+					for (const value of this.getSetCookie()) {
+						yield [name, value];
+					}
+				} else {
+					yield [name, this.get(name)];
+				}
+			}
+		}
+		*keys() {
+			for (const [name] of this.entries()) {
+				yield name;
+			}
+		}
+		*values() {
+			for (const [, value] of this.entries()) {
+				yield value;
+			}
+		}
+		[Symbol.iterator]() {
+			return this.entries();
+		}
+		get [Symbol.toStringTag]() {
+			return "Headers";
+		}
+	}
+
+	// the body is kept as an Uint8Array, and the content type is set if it's not given.
+	function extractBody(body, headers) {
+		if (body == null) {
+			return null;
+		}
+		let type = null, bytes;
+		if (body instanceof ArrayBuffer) {
+			bytes = new Uint8Array(body.slice(0));
+		} else if (ArrayBuffer.isView(body)) {
+			bytes = new Uint8Array(body.buffer.slice(body.byteOffset, body.byteOffset + body.byteLength));
+		} else if (typeof URLSearchParams === "function" && body instanceof URLSearchParams) {
+			bytes = encode(body.toString());
+			type = "application/x-www-form-urlencoded;charset=UTF-8";
+		} else {
+			bytes = encode(String(body));
+			type = "text/plain;charset=UTF-8";
+		}
+		if (type !== null && !headers.has("content-type")) {
+			headers.set("content-type", type);
+		}
+		return bytes;
+	}
+
+	let bodyBytes;
+	class Body {
+		#bytes;
+		#used = false;
+		constructor(body, headers) {
+			this.#bytes = extractBody(body, headers);
+		}
+		static {
+			bodyBytes = b => {
+				if (b.#used) {
+					throw new TypeError("body is already used");
+				}
+				return b.#bytes;
+			};
+		}
+		get bodyUsed() {
+			return this.#used;
+		}
+		#consume() {
+			if (this.#bytes === null) {
+				return Promise.resolve(new Uint8Array(0));
+			}
+			if (this.#used) {
+				return Promise.reject(new TypeError("body is already used"));
+			}
+			this.#used = true;
+			return Promise.resolve(this.#bytes);
+		}
+		arrayBuffer() {
+			return this.#consume().then(b => b.buffer.slice(b.byteOffset, b.byteOffset + b.byteLength));
+		}
+		bytes() {
+			return this.#consume().then(b => new Uint8Array(b));
+		}
+		text() {
+			return this.#consume().then(b => decode(b));
+		}
+		json() {
+			return this.text().then(JSON.parse);
+		}
+	}
+
+	const normalizedMethods = ["DELETE", "GET", "HEAD", "OPTIONS", "POST", "PUT"];
+	const redirectModes = ["follow", "error", "manual"];
+
+	class Request extends Body {
+		#method;
+		#url;
+		#headers;
+		#redirect;
+		constructor(input, init) {
+			init = init ?? {};
+			const source = input instanceof Request ? input : null;
+			const url = source !== null ? source.url : String(input);
+			let method = String(init.method ?? (source !== null ? source.method : "GET"));
+			if (normalizedMethods.includes(method.toUpperCase())) {
+				method = method.toUpperCase();
+			}
+			const redirect = String(init.redirect ?? (source !== null ? source.redirect : "follow"));
+			if (!redirectModes.includes(redirect)) {
+				throw new TypeError("invalid redirect mode: " + redirect);
+			}
+			const headers = new Headers(init.headers ?? (source !== null ? source.headers : undefined));
+			const body = init.body !== undefined ? init.body : (source !== null ? bodyBytes(source) : null);
+			if (body != null && (method === "GET" || method === "HEAD")) {
+				throw new TypeError("request with GET/HEAD method cannot have body");
+			}
+			super(body, headers);
+			this.#method = method;
+			this.#url = url;
+			this.#headers = headers;
+			this.#redirect = redirect;
+		}
+		get method() {
+			return this.#method;
+		}
+		get url() {
+			return this.#url;
+		}
+		get headers() {
+			return this.#headers;
+		}
+		get redirect() {
+			return this.#redirect;
+		}
+		clone() {
+			return new Request(this);
+		}
+		get [Symbol.toStringTag]() {
+			return "Request";
+		}
+	}
+
+	const nullBodyStatus = [101, 103, 204, 205, 304];
+	const redirectStatus = [301, 302, 303, 307, 308];
+
+	let makeResponse;
+	class Response extends Body {
+		#status;
+		#statusText;
+		#headers;
+		#url = "";
+		#redirected = false;
+		#type = "default";
+		constructor(body = null, init) {
+			init = init ?? {};
+			const status = init.status === undefined ? 200 : Number(init.status);
+			if (!Number.isInteger(status) || status < 200 || status > 599) {
+				throw new RangeError("invalid status: " + init.status);
+			}
+			const headers = new Headers(init.headers);
+			if (body != null && nullBodyStatus.includes(status)) {
+				throw new TypeError("response with null body status cannot have body");
+			}
+			super(body, headers);
+			this.#status = status;
+			this.#statusText = String(init.statusText ?? "");
+			this.#headers = headers;
+		}
+		static {
+			makeResponse = (body, init, status, url, redirected, type) => {
+				const res = new Response(body, init);
+				res.#status = status;
+				res.#url = url;
+				res.#redirected = redirected;
+				res.#type = type;
+				return res;
+			};
+		}
+		get status() {
+			return this.#status;
+		}
+		get ok() {
+			return this.#status >= 200 && this.#status < 300;
+		}
+		get statusText() {
+			return this.#statusText;
+		}
+		get headers() {
+			return this.#headers;
+		}
+		get url() {
+			return this.#url;
+		}
+		get redirected() {
+			return this.#redirected;
+		}
+		get type() {
+			return this.#type;
+		}
+		clone() {
+			const init = {statusText: this.#statusText, headers: this.#headers};
+			return makeResponse(bodyBytes(this), init, this.#status, this.#url, this.#redirected, this.#type);
+		}
+		static error() {
+			return makeResponse(null, {}, 0, "", false, "error");
+		}
+		static json(data, init) {
+			init = init ?? {};
+			const headers = new Headers(init.headers);
+			if (!headers.has("content-type")) {
+				headers.set("content-type", "application/json");
+			}
+			return new Response(JSON.stringify(data), {...init, headers});
+		}
+		static redirect(url, status = 302) {
+			if (!redirectStatus.includes(status)) {
+				throw new RangeError("invalid redirect status: " + status);
+			}
+			return makeResponse(null, {headers: {location: String(url)}}, status, "", false, "default");
+		}
+		get [Symbol.toStringTag]() {
+			return "Response";
+		}
+	}
+
+	async function fetch(input, init) {
+		const req = new Request(input, init);
+		const reqHeaders = [];
+		for (const [name, value] of req.headers) {
+			reqHeaders.push(name, value);
+		}
+		const [status, statusText, url, redirected, resHeaders, body] = await roundTrip(req.method, req.url, reqHeaders, bodyBytes(req), req.redirect);
+		const headers = new Headers();
+		for (let i = 0; i + 1 < resHeaders.length; i += 2) {
+			headers.append(resHeaders[i], resHeaders[i+1]);
+		}
+		const noBody = req.method === "HEAD" || nullBodyStatus.includes(status);
+		return makeResponse(noBody ? null : body, {statusText, headers}, status, url, redirected, "basic");
+	}
+
+	for (const [name, value] of Object.entries({fetch, Headers, Request, Response})) {
+		Object.defineProperty(globalThis, name, {value, writable: true, configurable: true});
+	}
+})`
+
+// EnableFetch adds `fetch()`, `Headers`, `Request` and `Response` to the global object. The
+// requests are sent by rt in goroutines, so fetch() returns a Promise settled when the pending
+// jobs are run, such as by Run and EvalAwait. The requests are canceled when the context.Context
+// of the call from Go is done. The bodies are read fully, streams are not supported. If rt is nil,
+// http.DefaultTransport is used.
+func (ctx *JsContext) EnableFetch(rt http.RoundTripper) (err error) {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	ctx.lock()
+	defer ctx.unlock()
+
+	c := ctx.c
+	encode := func(s string) Uint8Array {
+		return Uint8Array(s)
+	}
+	decode := func(b []byte) string {
+		return string(b)
+	}
+	res, e := evalPrelude(c, "<fetch>", fetchPrelude, Async(fetchRoundTrip(rt)), encode, decode)
+	if e != nil {
+		err = e
+		return
+	}
+	C.JS_FreeValue(c, res)
+	return
+}
+
+// make the func sending a request with rt. The headers are given and returned as name/value pairs.
+func fetchRoundTrip(rt http.RoundTripper) func(context.Context, string, string, []string, []byte, string) (int, string, string, bool, []string, Uint8Array, error) {
+	return func(goCtx context.Context, method, url string, headers []string, body []byte, redirect string) (status int, statusText string, resURL string, redirected bool, resHeaders []string, resBody Uint8Array, err error) {
+		var r io.Reader
+		if len(body) > 0 {
+			r = bytes.NewReader(body)
+		}
+		req, e := http.NewRequestWithContext(goCtx, method, url, r)
+		if e != nil {
+			err = e
+			return
+		}
+		for i:=0; i+1<len(headers); i+=2 {
+			req.Header.Add(headers[i], headers[i+1])
+		}
+
+		client := &http.Client{Transport: rt}
+		switch redirect {
+		case "error":
+			client.CheckRedirect = func(*http.Request, []*http.Request) error {
+				return fmt.Errorf("redirect mode is set to error")
+			}
+		case "manual":
+			client.CheckRedirect = func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			}
+		default:
+			// the request of the response may be a clone without any redirect, so the redirects followed are counted
+			client.CheckRedirect = func(_ *http.Request, via []*http.Request) error {
+				if len(via) >= 10 {
+					return fmt.Errorf("stopped after 10 redirects")
+				}
+				redirected = true
+				return nil
+			}
+		}
+		resp, e := client.Do(req)
+		if e != nil {
+			err = e
+			return
+		}
+		defer resp.Body.Close()
+		if resBody, err = io.ReadAll(resp.Body); err != nil {
+			return
+		}
+
+		status = resp.StatusCode
+		statusText = strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprintf("%d", status)))
+		// a RoundTripper may leave the request of the response nil
+		resReq := resp.Request
+		if resReq == nil {
+			resReq = req
+		}
+		resURL = resReq.URL.String()
+		names := make([]string, 0, len(resp.Header))
+		for name := range resp.Header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, value := range resp.Header[name] {
+				resHeaders = append(resHeaders, name, value)
+			}
+		}
+		return
+	}
+}
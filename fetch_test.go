@@ -0,0 +1,166 @@
+package quickjs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newFetchServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+		w.Header().Set("X-Token", r.Header.Get("X-Token"))
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		w.Write(body)
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, "teapot")
+	})
+	mux.HandleFunc("/no-content", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"name":"quickjs","n":1}`)
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/json", http.StatusFound)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5*time.Second):
+		}
+	})
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestFetch(t *testing.T) {
+	s := newFetchServer(t)
+	tests := []struct {
+		name   string
+		script string
+		want   interface{}
+	}{
+		{"status", `fetch(base + "/status").then(r => [r.status, r.ok, r.statusText])`,
+			[]interface{}{int64(418), false, "I'm a teapot"}},
+		{"text body", `fetch(base + "/status").then(r => r.text())`, "teapot"},
+		{"json body", `fetch(base + "/json").then(r => r.json()).then(v => v.name)`, "quickjs"},
+		{"null body status", `fetch(base + "/no-content").then(async r => [r.status, (await r.bytes()).length])`,
+			[]interface{}{int64(204), int64(0)}},
+		{"request body", `fetch(base + "/echo", {method: "post", body: "hello"}).then(async r => [r.headers.get("x-method"), r.headers.get("x-content-type"), await r.text()])`,
+			[]interface{}{"POST", "text/plain;charset=UTF-8", "hello"}},
+		{"bytes body", `fetch(base + "/echo", {method: "PUT", body: new Uint8Array([104, 105])}).then(r => r.text())`, "hi"},
+		{"request headers", `fetch(base + "/echo", {headers: {"X-Token": "secret"}}).then(r => r.headers.get("x-token"))`, "secret"},
+		{"set-cookie", `fetch(base + "/echo").then(r => r.headers.getSetCookie())`, []interface{}{"a=1", "b=2"}},
+		{"follow", `fetch(base + "/redirect").then(r => [r.status, r.redirected, r.url === base + "/json"])`,
+			[]interface{}{int64(200), true, true}},
+		{"not redirected", `fetch(base + "/json").then(r => [r.redirected, r.url === base + "/json", r.type])`,
+			[]interface{}{false, true, "basic"}},
+		{"manual", `fetch(base + "/redirect", {redirect: "manual"}).then(r => [r.status, r.redirected, r.headers.get("location")])`,
+			[]interface{}{int64(302), false, "/json"}},
+		{"redirect error", `fetch(base + "/redirect", {redirect: "error"}).then(() => "resolved", e => "rejected")`, "rejected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			if err := ctx.EnableFetch(nil); err != nil {
+				t.Fatalf("EnableFetch: %v", err)
+			}
+			goCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			res, err := ctx.EvalAwait(goCtx, tt.script, map[string]interface{}{"base": s.URL})
+			if err != nil {
+				t.Fatalf("EvalAwait: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchAborted(t *testing.T) {
+	s := newFetchServer(t)
+	ctx := newTestContext(t)
+	if err := ctx.EnableFetch(nil); err != nil {
+		t.Fatalf("EnableFetch: %v", err)
+	}
+	goCtx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := ctx.EvalAwait(goCtx, `fetch(base + "/slow")`, map[string]interface{}{"base": s.URL})
+	if err == nil {
+		t.Fatalf("the request is expected to be aborted")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("aborted after %v", d)
+	}
+}
+
+// a RoundTripper answering without setting the request of the response.
+type bareRoundTripper struct{}
+
+func (bareRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/plain"}},
+		Body:       io.NopCloser(strings.NewReader("bare")),
+	}, nil
+}
+
+func TestFetchResponseWithoutRequest(t *testing.T) {
+	ctx := newTestContext(t)
+	if err := ctx.EnableFetch(bareRoundTripper{}); err != nil {
+		t.Fatalf("EnableFetch: %v", err)
+	}
+	res, err := ctx.EvalAwait(context.Background(), `fetch("http://example.test/a").then(async r => [r.url, r.redirected, await r.text()])`, nil)
+	if err != nil {
+		t.Fatalf("EvalAwait: %v", err)
+	}
+	want := []interface{}{"http://example.test/a", false, "bare"}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("got %#v, want %#v", res, want)
+	}
+}
+
+// a RoundTripper answering with a clone of the request, as the wrapping transports may do.
+type cloningRoundTripper struct{}
+
+func (cloningRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader("cloned")),
+		Request:    req.Clone(req.Context()),
+	}, nil
+}
+
+func TestFetchClonedRequestNotRedirected(t *testing.T) {
+	ctx := newTestContext(t)
+	if err := ctx.EnableFetch(cloningRoundTripper{}); err != nil {
+		t.Fatalf("EnableFetch: %v", err)
+	}
+	res, err := ctx.EvalAwait(context.Background(), `fetch("http://example.test/a").then(r => [r.url, r.redirected])`, nil)
+	if err != nil {
+		t.Fatalf("EvalAwait: %v", err)
+	}
+	want := []interface{}{"http://example.test/a", false}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("got %#v, want %#v", res, want)
+	}
+}
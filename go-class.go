@@ -0,0 +1,229 @@
+package quickjs
+
+/*
+#include "go-proxy.h"
+#include <stdlib.h>
+
+extern JSValue goClassCtorBridge(JSContext *ctx, JSValueConst new_target, int argc, JSValueConst *argv, int magic);
+extern JSValue goMethodBridge(JSContext *ctx, JSValueConst this_val, int argc, JSValueConst *argv, int magic, JSValue *func_data);
+
+static JSValue newClassCtor(JSContext *ctx, const char *name, int length, int magic) {
+	return JS_NewCFunction2(ctx, (JSCFunction*)goClassCtorBridge, name, length, JS_CFUNC_constructor_magic, magic);
+}
+*/
+import "C"
+import (
+	"reflect"
+	"unsafe"
+	"fmt"
+	"strings"
+)
+
+type goClass struct {
+	name  string
+	t     reflect.Type  // the struct type
+	ctor  reflect.Value // invalid if no constructor given
+	proto C.JSValue
+}
+
+// RegisterClass makes a golang struct type be a JS class, so a script can create
+// an instance with `new className(...)`, test it with `instanceof className`, and
+// the methods of the struct are shared by the prototype of the class. Any value of
+// the type passed to JS later is also an instance of the class.
+// @param structType   reflect.TypeOf(T{}) or reflect.TypeOf(&T{})
+// @param constructor  a func returning T or *T, with an optional last error, which
+//                     is called with the arguments of `new`. If it is nil, `new`
+//                     creates a zero T.
+func (ctx *JsContext) RegisterClass(className string, structType reflect.Type, constructor interface{}) (err error) {
+	if len(className) == 0 {
+		err = fmt.Errorf("className must be non-empty")
+		return
+	}
+	if structType == nil {
+		err = fmt.Errorf("structType must be non-nil")
+		return
+	}
+	t := structType
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		err = fmt.Errorf("struct type expected to register class %s", className)
+		return
+	}
+	cls := &goClass{name: className, t: t}
+	argc := 0
+	if constructor != nil {
+		ctor := reflect.ValueOf(constructor)
+		ctorT := ctor.Type()
+		if ctorT.Kind() != reflect.Func || ctorT.NumOut() == 0 || (ctorT.Out(0) != t && ctorT.Out(0) != reflect.PtrTo(t)) {
+			err = fmt.Errorf("constructor of class %s expected to be a func returning %v or %v", className, t, reflect.PtrTo(t))
+			return
+		}
+		cls.ctor = ctor
+		argc = goFuncLength(ctorT)
+	}
+
+	ctx.lock()
+	defer ctx.unlock()
+
+	if _, ok := ctx.classes[t]; ok {
+		err = fmt.Errorf("type %v is already registered", t)
+		return
+	}
+
+	c := ctx.c
+	ptr := getPtrStore(uintptr(unsafe.Pointer(c)))
+	idx := ptr.register(cls)
+	jsIdx := C.JS_NewUint32(c, C.uint32_t(idx))
+	defer C.JS_FreeValue(c, jsIdx)
+
+	// methods of *T, including those of T, are shared by the prototype
+	cls.proto = C.JS_NewObject(c)
+	pv := reflect.New(t)
+	pt := pv.Type()
+	for i:=0; i<pt.NumMethod(); i++ {
+		m := pt.Method(i)
+		argc := goFuncLength(pv.Method(i).Type())
+		fn := C.JS_NewCFunctionData(c, (*C.JSCFunctionData)(C.goMethodBridge), C.int(argc), C.int(i), 1, (*C.JSValue)(unsafe.Pointer(&jsIdx)))
+		mName := C.CString(lowerFirst(m.Name))
+		C.JS_SetPropertyStr(c, cls.proto, mName, fn)
+		C.free(unsafe.Pointer(mName))
+	}
+
+	cName := C.CString(className)
+	defer C.free(unsafe.Pointer(cName))
+	ctorFn := C.newClassCtor(c, cName, C.int(argc), C.int(idx))
+	C.JS_SetConstructor(c, ctorFn, cls.proto)
+
+	global := C.JS_GetGlobalObject(c)
+	defer C.JS_FreeValue(c, global)
+	C.JS_SetPropertyStr(c, global, cName, ctorFn)
+
+	ctx.classes[t] = cls
+	return
+}
+
+func lookupGoClass(ctx *C.JSContext, idx uint32) (cls *goClass, ok bool) {
+	ptr := getPtrStore(uintptr(unsafe.Pointer(ctx)))
+	v, o := ptr.lookup(idx)
+	if !o {
+		return
+	}
+	cls, ok = v.(*goClass)
+	return
+}
+
+// find the class registered with type t, which is T or *T.
+func findGoClass(ctx *C.JSContext, t reflect.Type) *goClass {
+	jsCtx := getJsContext(ctx)
+	if jsCtx == nil {
+		return nil
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return jsCtx.classes[t]
+}
+
+func freeGoClasses(ctx *jsContext) {
+	for _, cls := range ctx.classes {
+		C.JS_FreeValue(ctx.c, cls.proto)
+	}
+	ctx.classes = nil
+}
+
+//export goClassCtorBridge
+func goClassCtorBridge(ctx *C.JSContext, new_target C.JSValueConst, argc C.int, argv *C.JSValueConst, magic C.int) (res C.JSValue) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = throwGoPanic(ctx, r)
+		}
+	}()
+
+	cls, ok := lookupGoClass(ctx, uint32(magic))
+	if !ok {
+		return C.toException()
+	}
+
+	var v interface{}
+	if cls.ctor.IsValid() {
+		results, err := callGoFunc(ctx, cls.ctor, C.toUndefined(), argc, argv, false)
+		if err != nil {
+			return throwGoError(ctx, err)
+		}
+		v = results[0]
+	} else {
+		v = reflect.New(cls.t).Interface()
+	}
+
+	// instances are always kept as *T
+	vv := reflect.ValueOf(v)
+	switch {
+	case !vv.IsValid(), vv.Kind() == reflect.Ptr && vv.IsNil():
+		return throwGoError(ctx, fmt.Errorf("constructor of %s returns nil", cls.name))
+	case vv.Kind() != reflect.Ptr:
+		p := reflect.New(cls.t)
+		p.Elem().Set(vv)
+		v = p.Interface()
+	}
+
+	// new_target may be a sub-class extending the class
+	proto := getPropertyStr(ctx, new_target, "prototype\x00")
+	defer C.JS_FreeValue(ctx, proto)
+
+	ptr := getPtrStore(uintptr(unsafe.Pointer(ctx)))
+	idx := ptr.register(&v)
+	return C.makeGoObjectWithProto(ctx, C.uint32_t(idx), proto)
+}
+
+//export goMethodBridge
+func goMethodBridge(ctx *C.JSContext, this_val C.JSValueConst, argc C.int, argv *C.JSValueConst, magic C.int, func_data *C.JSValue) (res C.JSValue) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = throwGoPanic(ctx, r)
+		}
+	}()
+
+	var jsIdx C.uint32_t
+	C.JS_ToUint32(ctx, &jsIdx, *func_data)
+	cls, ok := lookupGoClass(ctx, uint32(jsIdx))
+	if !ok {
+		return C.toException()
+	}
+
+	v, ok := getTargetValue(ctx, this_val)
+	if !ok || v == nil {
+		return throwGoError(ctx, fmt.Errorf("this is not an instance of %s", cls.name))
+	}
+	vv := reflect.ValueOf(v)
+	switch vv.Type() {
+	case cls.t:
+		p := reflect.New(cls.t)
+		p.Elem().Set(vv)
+		vv = p
+	case reflect.PtrTo(cls.t):
+	default:
+		return throwGoError(ctx, fmt.Errorf("this is not an instance of %s", cls.name))
+	}
+
+	results, e := callGoFunc(ctx, vv.Method(int(magic)), this_val, argc, argv, false)
+	return goFuncResult(ctx, results, e)
+}
+
+// fields are got from the Go value, others from the prototype chain of the class.
+func go_class_get(ctx *C.JSContext, obj C.JSValueConst, vv reflect.Value, atom C.JSAtom, key string, receiver C.JSValueConst) C.JSValue {
+	if structE := reflect.Indirect(vv); structE.IsValid() {
+		if fv := structE.FieldByName(upperFirst(key)); fv.IsValid() && fv.CanInterface() {
+			v, _ := makeJsValue(ctx, fv.Interface())
+			return v
+		}
+	}
+	proto := C.JS_GetPrototype(ctx, obj)
+	defer C.JS_FreeValue(ctx, proto)
+	return C.JS_GetPropertyInternal(ctx, proto, atom, receiver, 0)
+}
+
+func lowerFirst(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
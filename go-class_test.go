@@ -0,0 +1,118 @@
+package quickjs
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type testPoint struct {
+	X, Y int
+}
+
+func (p *testPoint) Sum() int {
+	return p.X + p.Y
+}
+
+func (p testPoint) Scale(n int) testPoint {
+	return testPoint{X: p.X * n, Y: p.Y * n}
+}
+
+func newTestPoint(x, y int) (*testPoint, error) {
+	if x < 0 || y < 0 {
+		return nil, fmt.Errorf("negative point")
+	}
+	return &testPoint{X: x, Y: y}, nil
+}
+
+func TestRegisterClass(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   interface{}
+	}{
+		{"method", `new Point(1, 2).sum()`, int64(3)},
+		{"method of value", `new Point(1, 2).scale(2).sum()`, int64(6)},
+		{"instanceof", `new Point(1, 2) instanceof Point`, true},
+		{"field", `new Point(1, 2).y`, int64(2)},
+		{"set field", `const p = new Point(1, 2); p.x = 5; p.sum()`, int64(7)},
+		{"prototype", `Object.getPrototypeOf(new Point(0, 0)) === Point.prototype`, true},
+		{"constructor length", `Point.length`, int64(2)},
+		{"sub-class", `
+			class Point3 extends Point {
+				z() {
+					return 3;
+				}
+			}
+			const p = new Point3(1, 1);
+			[p instanceof Point, p instanceof Point3, p.sum(), p.z()]`, []interface{}{true, true, int64(2), int64(3)}},
+		{"Go value", `origin instanceof Point && origin.sum() === 0`, true},
+		{"constructor error", `try { new Point(-1, 0) } catch (e) { e.message }`, "negative point"},
+		{"wrong this", `try { Point.prototype.sum.call({}) } catch (e) { e.message }`, "this is not an instance of Point"},
+		{"zero value", `const e = new Empty(); e instanceof Empty`, true},
+	}
+	type empty struct{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			if err := ctx.RegisterClass("Point", reflect.TypeOf(testPoint{}), newTestPoint); err != nil {
+				t.Fatalf("RegisterClass: %v", err)
+			}
+			if err := ctx.RegisterClass("Empty", reflect.TypeOf(&empty{}), nil); err != nil {
+				t.Fatalf("RegisterClass: %v", err)
+			}
+			res, err := ctx.Eval(tt.script, map[string]interface{}{"origin": &testPoint{}})
+			if err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterClassErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		className string
+		typ       reflect.Type
+		ctor      interface{}
+	}{
+		{"empty name", "", reflect.TypeOf(testPoint{}), nil},
+		{"nil type", "Point", nil, nil},
+		{"not struct", "Point", reflect.TypeOf(0), nil},
+		{"not func", "Point", reflect.TypeOf(testPoint{}), 1},
+		{"wrong result", "Point", reflect.TypeOf(testPoint{}), func() int { return 0 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			if err := ctx.RegisterClass(tt.className, tt.typ, tt.ctor); err == nil {
+				t.Errorf("error expected")
+			}
+		})
+	}
+
+	ctx := newTestContext(t)
+	if err := ctx.RegisterClass("Point", reflect.TypeOf(testPoint{}), nil); err != nil {
+		t.Fatalf("RegisterClass: %v", err)
+	}
+	if err := ctx.RegisterClass("Point2", reflect.TypeOf(&testPoint{}), nil); err == nil {
+		t.Errorf("registering a type twice is expected to fail")
+	}
+}
+
+func TestClassInstanceToGo(t *testing.T) {
+	ctx := newTestContext(t)
+	if err := ctx.RegisterClass("Point", reflect.TypeOf(testPoint{}), newTestPoint); err != nil {
+		t.Fatalf("RegisterClass: %v", err)
+	}
+	res, err := ctx.Eval(`new Point(3, 4)`, nil)
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if p, ok := res.(*testPoint); !ok || *p != (testPoint{X: 3, Y: 4}) {
+		t.Errorf("got %#v", res)
+	}
+}
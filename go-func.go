@@ -12,19 +12,50 @@ extern JSValue goFuncBridge(JSContext *ctx, JSValueConst this_val, int argc, JSV
 import "C"
 import (
 	elutils "github.com/rosbit/go-embedding-utils"
+	"context"
 	"reflect"
 	"unsafe"
+	"fmt"
+)
+
+// CallInfo can be taken as a leading argument of a golang func called by JS,
+// which is injected instead of being converted from the script arguments.
+// A leading *JsContext or context.Context is injected in the same way.
+type CallInfo struct {
+	Context   context.Context // passed to EvalContext, CallFuncContext and so on
+	JsContext *JsContext      // only valid during the call
+	This      JsValue
+	Args      []JsValue
+	Argc      int
+}
+
+var (
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+	jsContextType = reflect.TypeOf((*JsContext)(nil))
+	goContextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	callInfoType  = reflect.TypeOf((*CallInfo)(nil))
+)
+
+const (
+	goFuncSync = iota
+	goFuncAsync
 )
 
 func bindGoFunc(ctx *C.JSContext, fnVarPtr interface{}) (goFunc C.JSValue) {
 	fnVar := reflect.ValueOf(fnVarPtr)
 	t := fnVar.Type()
-	goFunc = wrapGoFunc(ctx, fnVarPtr, t)
+	goFunc = wrapGoFunc(ctx, fnVarPtr, t, goFuncSync)
 	return
 }
 
 //export goFuncBridge
-func goFuncBridge(ctx *C.JSContext, this_val C.JSValueConst, argc C.int, argv *C.JSValueConst, magic C.int, func_data *C.JSValue) C.JSValue {
+func goFuncBridge(ctx *C.JSContext, this_val C.JSValueConst, argc C.int, argv *C.JSValueConst, magic C.int, func_data *C.JSValue) (res C.JSValue) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = throwGoPanic(ctx, r)
+		}
+	}()
+
 	// get function idx
 	var jsIdx C.uint32_t
 	C.JS_ToUint32(ctx, &jsIdx, *func_data)
@@ -44,42 +75,256 @@ func goFuncBridge(ctx *C.JSContext, this_val C.JSValueConst, argc C.int, argv *C
 	if fnVal.Kind() != reflect.Func {
 		return C.toException()
 	}
-	fnType := fnVal.Type()
 
-	helper := elutils.NewGolangFuncHelperDirectly(fnVal, fnType)
-	getArgs := func(i int) interface{} {
-		jsArg := C.getArg(argv, C.int(i))
-		if goVal, err := fromJsValue(ctx, jsArg); err == nil {
-			return goVal
-		}
-		return nil
+	if magic == goFuncAsync {
+		return callGoFuncAsync(ctx, fnVal, this_val, argc, argv)
 	}
-	v, e := helper.CallGolangFunc(int(argc), "qjs-func", getArgs)
+	results, e := callGoFunc(ctx, fnVal, this_val, argc, argv, true)
+	return goFuncResult(ctx, results, e)
+}
+
+// call a golang func with the JS arguments, fnVal must be with kind reflect.Func.
+// A non-nil error as the last result is returned as err and removed from results.
+// If optional is true, the trailing pointer or interface args can be omitted.
+func callGoFunc(ctx *C.JSContext, fnVal reflect.Value, this_val C.JSValueConst, argc C.int, argv *C.JSValueConst, optional bool) (results []interface{}, err error) {
+	goArgs, e := makeGoArgs(ctx, fnVal.Type(), this_val, argc, argv, optional)
 	if e != nil {
-		emsg := makeString(ctx, e.Error())
-		return C.JS_Throw(ctx, emsg)
+		err = e
+		return
 	}
+	return goCallResults(fnVal.Type(), fnVal.Call(goArgs))
+}
 
-	if v == nil {
-		return C.toException()
+// convert the JS arguments to the args of a golang func with type fnType.
+func makeGoArgs(ctx *C.JSContext, fnType reflect.Type, this_val C.JSValueConst, argc C.int, argv *C.JSValueConst, optional bool) (goArgs []reflect.Value, err error) {
+	injected := injectedArgsNum(fnType)
+	variadic := fnType.IsVariadic()
+	lastNumIn := fnType.NumIn() - 1
+	fixedNum := fnType.NumIn() - injected // number of args not variadic
+	if variadic {
+		fixedNum -= 1
+	}
+	required := requiredArgsNum(fnType, injected, optional)
+	argsNum := int(argc)
+	if !variadic && argsNum > fixedNum && takesCallInfo(fnType, injected) {
+		// all the args can be got from CallInfo.Args
+		argsNum = fixedNum
+	}
+	if variadic {
+		if argsNum < required {
+			err = fmt.Errorf("at least %d args expected", required)
+			return
+		}
+	} else {
+		if argsNum < required || argsNum > fixedNum {
+			if required == fixedNum {
+				err = fmt.Errorf("%d args expected", fixedNum)
+			} else {
+				err = fmt.Errorf("%d to %d args expected", required, fixedNum)
+			}
+			return
+		}
+	}
+
+	// make golang func args
+	n := argsNum
+	if n < fixedNum {
+		n = fixedNum
+	}
+	goArgs = make([]reflect.Value, injected + n)
+	injectArgs(ctx, fnType, this_val, argc, argv, goArgs[:injected])
+	var fnArgType reflect.Type
+	for i:=0; i<n; i++ {
+		j := injected + i
+		if j<lastNumIn || !variadic {
+			fnArgType = fnType.In(j)
+		} else {
+			fnArgType = fnType.In(lastNumIn).Elem()
+		}
+		if i >= argsNum {
+			// optional arg not given
+			goArgs[j] = reflect.Zero(fnArgType)
+			continue
+		}
+
+		arg := C.getArg(argv, C.int(i))
+		if isBytesType(fnArgType) {
+			if b, ok := getBufferBytes(ctx, arg); ok {
+				goArgs[j] = reflect.ValueOf(b).Convert(fnArgType)
+				continue
+			}
+		}
+		goArgs[j] = elutils.MakeValue(fnArgType)
+		if goVal, e := fromJsValue(ctx, arg); e == nil {
+			elutils.SetValue(goArgs[j], goVal)
+		}
+		if goArgs[j].Type() != fnArgType {
+			// a string is made for []byte
+			goArgs[j] = goArgs[j].Convert(fnArgType)
+		}
+	}
+	return
+}
+
+// ArrayBuffer, typed arrays and DataView are converted to the args with type []byte
+func isBytesType(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+// split the results of a golang func call to the values and the last error.
+func goCallResults(fnType reflect.Type, res []reflect.Value) (results []interface{}, err error) {
+	retc := len(res)
+	if retc > 0 && fnType.Out(retc-1) == errorType {
+		if e := res[retc-1].Interface(); e != nil {
+			err = e.(error)
+			return
+		}
+		retc -= 1
+	}
+	results = make([]interface{}, retc)
+	for i:=0; i<retc; i++ {
+		results[i] = res[i].Interface()
 	}
+	return
+}
+
+// the number of the leading args of fnType injected by injectArgs
+func injectedArgsNum(fnType reflect.Type) (n int) {
+	for ; n < fnType.NumIn(); n++ {
+		switch fnType.In(n) {
+		case jsContextType, goContextType, callInfoType:
+		default:
+			return
+		}
+	}
+	return
+}
+
+func takesCallInfo(fnType reflect.Type, injected int) bool {
+	for i:=0; i<injected; i++ {
+		if fnType.In(i) == callInfoType {
+			return true
+		}
+	}
+	return false
+}
+
+// the number of args must be given by the script. If optional is true, the trailing
+// pointer or interface args are optional, nil is passed if they are not given.
+func requiredArgsNum(fnType reflect.Type, injected int, optional bool) int {
+	n := fnType.NumIn()
+	if fnType.IsVariadic() {
+		n -= 1
+	}
+	if !optional {
+		return n - injected
+	}
+	for ; n > injected; n-- {
+		switch fnType.In(n-1).Kind() {
+		case reflect.Ptr, reflect.Interface:
+		default:
+			return n - injected
+		}
+	}
+	return 0
+}
 
-	jsVal, err := makeJsValue(ctx, v)
+// the length of JS function for fnType
+func goFuncLength(fnType reflect.Type) int {
+	return fnType.NumIn() - injectedArgsNum(fnType)
+}
+
+func injectArgs(ctx *C.JSContext, fnType reflect.Type, this_val C.JSValueConst, argc C.int, argv *C.JSValueConst, goArgs []reflect.Value) {
+	if len(goArgs) == 0 {
+		return
+	}
+	jsCtx := getJsContext(ctx)
+	handle := &JsContext{jsContext: jsCtx, inCallback: true}
+	for i := range goArgs {
+		switch fnType.In(i) {
+		case jsContextType:
+			goArgs[i] = reflect.ValueOf(handle)
+		case goContextType:
+			goArgs[i] = reflect.ValueOf(jsCtx.goCtx)
+		case callInfoType:
+			args := make([]JsValue, int(argc))
+			for j := range args {
+				args[j] = JsValue{ctx: ctx, v: C.getArg(argv, C.int(j))}
+			}
+			goArgs[i] = reflect.ValueOf(&CallInfo{
+				Context: jsCtx.goCtx,
+				JsContext: handle,
+				This: JsValue{ctx: ctx, v: this_val},
+				Args: args,
+				Argc: int(argc),
+			})
+		}
+	}
+}
+
+// convert the results of callGoFunc to JS value:
+//  - no result, or only a nil error: undefined
+//  - a non-nil error: thrown as a JS Error
+//  - one result: the JS value of it
+//  - more results: an array of them
+func goFuncResult(ctx *C.JSContext, results []interface{}, e error) C.JSValue {
+	if e != nil {
+		return throwGoError(ctx, e)
+	}
+	jsVal, err := makeGoFuncResult(ctx, results)
 	if err != nil {
-		emsg := makeString(ctx, err.Error())
-		return C.JS_Throw(ctx, emsg)
+		return throwGoError(ctx, err)
 	}
 	return jsVal
 }
 
-func wrapGoFunc(ctx *C.JSContext, fnVar interface{}, fnType reflect.Type) C.JSValue {
+// convert the results without error to a JS value as goFuncResult does.
+func makeGoFuncResult(ctx *C.JSContext, results []interface{}) (C.JSValue, error) {
+	switch len(results) {
+	case 0:
+		return C.toUndefined(), nil
+	case 1:
+		return makeJsValue(ctx, results[0])
+	default:
+		jsArr := C.JS_NewArray(ctx)
+		for i, v := range results {
+			jsVal, err := makeJsValue(ctx, v)
+			if err != nil {
+				C.JS_FreeValue(ctx, jsArr)
+				return C.toUndefined(), err
+			}
+			C.JS_SetPropertyUint32(ctx, jsArr, C.uint32_t(i), jsVal)
+		}
+		return jsArr, nil
+	}
+}
+
+// throw a JS Error with the message of err, and the golang type of err as property `goType`.
+func throwGoError(ctx *C.JSContext, err error) C.JSValue {
+	return C.JS_Throw(ctx, newGoError(ctx, err))
+}
+
+func newGoError(ctx *C.JSContext, err error) C.JSValue {
+	errObj := C.JS_NewError(ctx)
+	setPropertyStr(ctx, errObj, "message\x00", makeString(ctx, err.Error()))
+	setPropertyStr(ctx, errObj, "goType\x00", makeString(ctx, fmt.Sprintf("%T", err)))
+	// keep err itself, so it will be returned when the exception gets back to Go
+	goErr := "goError\x00"
+	var prop *C.char
+	getStrPtr(&goErr, &prop)
+	C.JS_DefinePropertyValueStr(ctx, errObj, prop, makeGoObject(ctx, err), C.JS_PROP_CONFIGURABLE)
+	return errObj
+}
+
+func wrapGoFunc(ctx *C.JSContext, fnVar interface{}, fnType reflect.Type, magic int) C.JSValue {
 	ptr := getPtrStore(uintptr(unsafe.Pointer(ctx)))
 	idx := ptr.register(&fnVar)
 	jsIdx := C.JS_NewUint32(ctx, C.uint32_t(idx))
 	defer C.JS_FreeValue(ctx, jsIdx)
 
 	// create a JS function
-	argc := fnType.NumIn()
-	return C.JS_NewCFunctionData(ctx, (*C.JSCFunctionData)(C.goFuncBridge), C.int(argc), 0, 1, (*C.JSValue)(unsafe.Pointer(&jsIdx)))
+	// the trailing pointer or interface args are optional
+	argc := requiredArgsNum(fnType, injectedArgsNum(fnType), true)
+	return C.JS_NewCFunctionData(ctx, (*C.JSCFunctionData)(C.goFuncBridge), C.int(argc), C.int(magic), 1, (*C.JSValue)(unsafe.Pointer(&jsIdx)))
 }
 
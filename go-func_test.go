@@ -0,0 +1,175 @@
+package quickjs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testError struct {
+	code int
+}
+
+func (e *testError) Error() string {
+	return "test error"
+}
+
+func TestGoFuncResults(t *testing.T) {
+	tests := []struct {
+		name   string
+		fn     interface{}
+		script string
+		want   interface{}
+	}{
+		{"no result", func() {}, `f()`, nil},
+		{"nil error", func() error { return nil }, `f()`, nil},
+		{"one result", func() int { return 1 }, `f()`, int64(1)},
+		{"result and nil error", func() (string, error) { return "a", nil }, `f()`, "a"},
+		{"results", func() (int, string) { return 1, "a" }, `f()`, []interface{}{int64(1), "a"}},
+		{"results and nil error", func() (int, string, error) { return 1, "a", nil }, `f()`, []interface{}{int64(1), "a"}},
+		{"error thrown", func() error { return errors.New("failed") }, `try { f() } catch (e) { [e instanceof Error, e.message] }`,
+			[]interface{}{true, "failed"}},
+		{"error with results", func() (int, error) { return 1, errors.New("failed") }, `try { f() } catch (e) { e.message }`, "failed"},
+		{"goType", func() error { return &testError{} }, `try { f() } catch (e) { e.goType }`, "*quickjs.testError"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			res, err := ctx.Eval(tt.script, map[string]interface{}{"f": tt.fn})
+			if err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoFuncErrorBackToGo(t *testing.T) {
+	ctx := newTestContext(t)
+	e := &testError{code: 1}
+	_, err := ctx.Eval(`f()`, map[string]interface{}{"f": func() error { return e }})
+	var te *testError
+	if !errors.As(err, &te) || te != e {
+		t.Errorf("got %v, want the error returned by the Go func", err)
+	}
+}
+
+// a HostObject panicking in Get.
+type panickingHost struct {
+	testHost
+}
+
+func (h *panickingHost) Get(key string) (interface{}, bool) {
+	panic("get " + key)
+}
+
+func TestGoPanic(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		value  interface{}
+	}{
+		{"func", `f()`, "boom"},
+		{"error value", `fe()`, errBoom},
+		{"method", `new Panicker().run()`, "run"},
+		{"constructor", `new Panicker(1)`, "ctor"},
+		{"host object", `h.a`, "get a"},
+		{"in callback", `[1].map(() => f())`, "boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			if err := ctx.RegisterClass("Panicker", reflect.TypeOf(panicker{}), newPanicker); err != nil {
+				t.Fatalf("RegisterClass: %v", err)
+			}
+			env := map[string]interface{}{
+				"f": func() { panic("boom") },
+				"fe": func() { panic(errBoom) },
+				"h": HostObject(&panickingHost{}),
+			}
+			_, err := ctx.Eval(tt.script, env)
+			var pe *GoPanicError
+			if !errors.As(err, &pe) {
+				t.Fatalf("got %v, want GoPanicError", err)
+			}
+			if pe.Value != tt.value || len(pe.Stack) == 0 {
+				t.Errorf("got %#v, want %#v with the stack", pe.Value, tt.value)
+			}
+		})
+	}
+}
+
+func TestGoPanicCaught(t *testing.T) {
+	ctx := newTestContext(t)
+	res, err := ctx.Eval(`try { f() } catch (e) { [e instanceof Error, e.message, e.goType, e.goStack.length > 0] }`,
+		map[string]interface{}{"f": func() { panic("boom") }})
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	want := []interface{}{true, "golang panic: boom", "*quickjs.GoPanicError", true}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("got %#v, want %#v", res, want)
+	}
+	// the context is still usable
+	if res, err = ctx.Eval(`1 + 1`, nil); err != nil || res != int64(2) {
+		t.Errorf("got %v, %v", res, err)
+	}
+}
+
+var errBoom = errors.New("boom")
+
+type panicker struct{}
+
+func (p *panicker) Run() {
+	panic("run")
+}
+
+func newPanicker(args ...int) *panicker {
+	if len(args) > 0 {
+		panic("ctor")
+	}
+	return &panicker{}
+}
+
+func TestGoFuncInjection(t *testing.T) {
+	type key struct{}
+	tests := []struct {
+		name   string
+		fn     interface{}
+		script string
+		want   interface{}
+	}{
+		{"context.Context", func(goCtx context.Context) interface{} { return goCtx.Value(key{}) }, `f()`, "value"},
+		{"context.Context and args", func(goCtx context.Context, a, b int) int { return a + b }, `f(1, 2)`, int64(3)},
+		{"JsContext", func(ctx *JsContext) (interface{}, error) { return ctx.GetGlobal("g") }, `var g = "global"; f()`, "global"},
+		{"JsContext closed", func(ctx *JsContext) { ctx.Close() }, `f(); 1`, int64(1)},
+		{"CallInfo", func(ci *CallInfo) []interface{} {
+			this, _ := ci.This.Value()
+			args := []interface{}{ci.Argc, this.(map[string]interface{})["n"]}
+			for _, arg := range ci.Args {
+				args = append(args, arg.IsUndefined())
+			}
+			return args
+		}, `({n: 1, f}).f(1, undefined)`, []interface{}{2, int64(1), false, true}},
+		{"all injected", func(ctx *JsContext, goCtx context.Context, ci *CallInfo, s string) string {
+			return s + ci.Args[0].String()
+		}, `f("a")`, "aa"},
+		{"length", func(goCtx context.Context, ci *CallInfo, a, b int) {}, `f.length`, int64(2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			goCtx := context.WithValue(context.Background(), key{}, "value")
+			res, err := ctx.EvalContext(goCtx, tt.script, map[string]interface{}{"f": tt.fn})
+			if err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
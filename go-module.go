@@ -0,0 +1,223 @@
+package quickjs
+
+/*
+#include "quickjs-libc.h"
+#include <stdlib.h>
+
+extern int goModuleInit(JSContext *ctx, JSModuleDef *m);
+
+static JSModuleDef *newGoModule(JSContext *ctx, const char *name) {
+	return JS_NewCModule(ctx, name, goModuleInit);
+}
+*/
+import "C"
+import (
+	"unsafe"
+	"fmt"
+)
+
+// ModuleFactory creates the exports of a module when it is imported the first time.
+type ModuleFactory func() (exports map[string]interface{}, err error)
+
+type goModule struct {
+	exports map[string]interface{}
+	factory ModuleFactory
+}
+
+// RegisterModule makes a native ES module with golang values, so a script can
+// `import { name } from "moduleName"`. Use key "default" for the default export.
+func (ctx *JsContext) RegisterModule(moduleName string, exports map[string]interface{}) error {
+	if exports == nil {
+		return fmt.Errorf("exports of module %s must be non-nil", moduleName)
+	}
+	return ctx.registerModule(moduleName, &goModule{exports: exports})
+}
+
+// RegisterModuleFactory is same as RegisterModule, but the exports are created
+// by factory when the module is imported the first time.
+func (ctx *JsContext) RegisterModuleFactory(moduleName string, factory ModuleFactory) error {
+	if factory == nil {
+		return fmt.Errorf("factory of module %s must be non-nil", moduleName)
+	}
+	return ctx.registerModule(moduleName, &goModule{factory: factory})
+}
+
+func (ctx *JsContext) registerModule(moduleName string, m *goModule) (err error) {
+	if len(moduleName) == 0 {
+		err = fmt.Errorf("moduleName must be non-empty")
+		return
+	}
+
+	ctx.lock()
+	defer ctx.unlock()
+
+	if _, ok := ctx.modules[moduleName]; ok {
+		err = fmt.Errorf("module %s is already registered", moduleName)
+		return
+	}
+	ctx.modules[moduleName] = m
+	return
+}
+
+// record a module loaded from the files or the loader, so the cached contexts
+// are reloaded when it's modified.
+func (ctx *jsContext) addDep(moduleName string) {
+	for _, dep := range ctx.deps {
+		if dep == moduleName {
+			return
+		}
+	}
+	ctx.deps = append(ctx.deps, moduleName)
+}
+
+// get the exports of a registered module, the factory is called if needed.
+func (m *goModule) getExports() (map[string]interface{}, error) {
+	if m.exports == nil {
+		exports, err := m.factory()
+		if err != nil {
+			return nil, err
+		}
+		if exports == nil {
+			exports = map[string]interface{}{}
+		}
+		m.exports = exports
+	}
+	return m.exports, nil
+}
+
+//export goModuleLoader
+func goModuleLoader(ctx *C.JSContext, module_name *C.char, opaque unsafe.Pointer) (m *C.JSModuleDef) {
+	defer func() {
+		if r := recover(); r != nil {
+			throwGoPanic(ctx, r)
+			m = nil
+		}
+	}()
+
+	jsCtx := getJsContext(ctx)
+	if jsCtx == nil {
+		return C.js_module_loader(ctx, module_name, opaque)
+	}
+	moduleName := C.GoString(module_name)
+	goMod, ok := jsCtx.modules[moduleName]
+	if !ok {
+		name, _ := splitModuleQuery(moduleName)
+		jsCtx.addDep(name)
+		if moduleType := getModuleType(moduleName); len(moduleType) > 0 {
+			return loadDataModule(ctx, jsCtx, module_name, moduleType)
+		}
+		if jsCtx.loader != nil {
+			return loadModule(ctx, jsCtx.loader, module_name)
+		}
+		if m = C.js_module_loader(ctx, module_name, opaque); m == nil || setImportMeta(ctx, m) != 0 {
+			return nil
+		}
+		return m
+	}
+
+	exports, err := goMod.getExports()
+	if err != nil {
+		throwGoError(ctx, err)
+		return nil
+	}
+	m = C.newGoModule(ctx, module_name)
+	if m == nil {
+		return nil
+	}
+	for name, _ := range exports {
+		cName := C.CString(name)
+		C.JS_AddModuleExport(ctx, m, cName)
+		C.free(unsafe.Pointer(cName))
+	}
+	return m
+}
+
+//export goModuleInit
+func goModuleInit(ctx *C.JSContext, m *C.JSModuleDef) (res C.int) {
+	defer func() {
+		if r := recover(); r != nil {
+			throwGoPanic(ctx, r)
+			res = -1
+		}
+	}()
+
+	atom := C.JS_GetModuleName(ctx, m)
+	cName := C.JS_AtomToCString(ctx, atom)
+	moduleName := C.GoString(cName)
+	C.JS_FreeCString(ctx, cName)
+	C.JS_FreeAtom(ctx, atom)
+
+	jsCtx := getJsContext(ctx)
+	if jsCtx == nil {
+		return -1
+	}
+	if v, ok := jsCtx.dataModules[moduleName]; ok {
+		delete(jsCtx.dataModules, moduleName)
+		defaultExport := "default\x00"
+		var cName *C.char
+		getStrPtr(&defaultExport, &cName)
+		C.JS_SetModuleExport(ctx, m, cName, v)
+		return 0
+	}
+	goMod, ok := jsCtx.modules[moduleName]
+	if !ok {
+		return -1
+	}
+	exports, err := goMod.getExports()
+	if err != nil {
+		throwGoError(ctx, err)
+		return -1
+	}
+	for name, v := range exports {
+		jsVal, err := makeJsValue(ctx, v)
+		if err != nil {
+			throwGoError(ctx, err)
+			return -1
+		}
+		cName := C.CString(name)
+		C.JS_SetModuleExport(ctx, m, cName, jsVal)
+		C.free(unsafe.Pointer(cName))
+	}
+	return 0
+}
+
+// load a module of JSON, text or bytes, whose content is the default export.
+func loadDataModule(ctx *C.JSContext, jsCtx *jsContext, module_name *C.char, moduleType string) *C.JSModuleDef {
+	moduleName := C.GoString(module_name)
+	name, _ := splitModuleQuery(moduleName)
+	source, err := loadModuleSource(jsCtx.loader, name)
+	if err != nil {
+		throwGoError(ctx, err)
+		return nil
+	}
+
+	var v C.JSValue
+	switch moduleType {
+	case "json":
+		cSource := C.CString(source)
+		defer C.free(unsafe.Pointer(cSource))
+		v = C.JS_ParseJSON(ctx, cSource, C.size_t(len(source)), module_name)
+		if C.JS_IsException(v) != 0 {
+			return nil
+		}
+	case "text":
+		v = makeString(ctx, source)
+	case "bytes":
+		v = makeUint8Array(ctx, []byte(source))
+	default:
+		throwGoError(ctx, fmt.Errorf("unsupported type '%s' of module '%s'", moduleType, name))
+		return nil
+	}
+
+	m := C.newGoModule(ctx, module_name)
+	if m == nil {
+		C.JS_FreeValue(ctx, v)
+		return nil
+	}
+	defaultExport := "default\x00"
+	var cName *C.char
+	getStrPtr(&defaultExport, &cName)
+	C.JS_AddModuleExport(ctx, m, cName)
+	jsCtx.dataModules[moduleName] = v
+	return m
+}
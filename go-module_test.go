@@ -0,0 +1,95 @@
+package quickjs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRegisterModule(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   interface{}
+	}{
+		{"named", `import { currency } from "billing"; export const res = currency;`, "USD"},
+		{"func", `import { charge } from "billing"; export const res = charge(2);`, int64(20)},
+		{"default", `import billing from "billing"; export const res = billing.name;`, "billing"},
+		{"namespace", `import * as b from "billing"; export const res = Object.keys(b).sort().join();`, "charge,currency,default"},
+		{"factory", `import { n } from "lazy"; import { n as m } from "lazy"; export const res = n + m;`, int64(2)},
+		{"dynamic import", `export const res = await import("billing").then(b => b.currency);`, "USD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			err := ctx.RegisterModule("billing", map[string]interface{}{
+				"charge": func(n int) int { return n * 10 },
+				"currency": "USD",
+				"default": map[string]interface{}{"name": "billing"},
+			})
+			if err != nil {
+				t.Fatalf("RegisterModule: %v", err)
+			}
+			calls := 0
+			err = ctx.RegisterModuleFactory("lazy", func() (map[string]interface{}, error) {
+				calls++
+				return map[string]interface{}{"n": calls}, nil
+			})
+			if err != nil {
+				t.Fatalf("RegisterModuleFactory: %v", err)
+			}
+			m, err := ctx.EvalModule("main.js", tt.source)
+			if err != nil {
+				t.Fatalf("EvalModule: %v", err)
+			}
+			res, err := m.Get("res")
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestModuleFactoryLazy(t *testing.T) {
+	ctx := newTestContext(t)
+	errFactory := errors.New("factory failed")
+	called := false
+	err := ctx.RegisterModuleFactory("broken", func() (map[string]interface{}, error) {
+		called = true
+		return nil, errFactory
+	})
+	if err != nil {
+		t.Fatalf("RegisterModuleFactory: %v", err)
+	}
+	if called {
+		t.Errorf("the factory is called before the module is imported")
+	}
+	if _, err = ctx.EvalModule("main.js", `import "broken";`); err == nil {
+		t.Errorf("the error of the factory is expected")
+	}
+	if !called {
+		t.Errorf("the factory is not called")
+	}
+}
+
+func TestRegisterModuleErrors(t *testing.T) {
+	ctx := newTestContext(t)
+	if err := ctx.RegisterModule("", map[string]interface{}{}); err == nil {
+		t.Errorf("an empty module name is expected to fail")
+	}
+	if err := ctx.RegisterModule("m", nil); err == nil {
+		t.Errorf("nil exports are expected to fail")
+	}
+	if err := ctx.RegisterModuleFactory("m", nil); err == nil {
+		t.Errorf("a nil factory is expected to fail")
+	}
+	if err := ctx.RegisterModule("m", map[string]interface{}{}); err != nil {
+		t.Fatalf("RegisterModule: %v", err)
+	}
+	if err := ctx.RegisterModule("m", map[string]interface{}{}); err == nil {
+		t.Errorf("registering a module twice is expected to fail")
+	}
+}
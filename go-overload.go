@@ -0,0 +1,180 @@
+package quickjs
+
+/*
+#include "go-proxy.h"
+#include <stdlib.h>
+
+static JSValueConst getOverloadArg(JSValueConst *argv, int i) {
+	return argv[i];
+}
+extern JSValue goOverloadBridge(JSContext *ctx, JSValueConst this_val, int argc, JSValueConst *argv, int magic, JSValue *func_data);
+*/
+import "C"
+import (
+	"reflect"
+	"unsafe"
+	"fmt"
+)
+
+type goOverloads struct {
+	name string
+	fns  []reflect.Value
+}
+
+// RegisterOverloads sets several golang funcs as a global JS function named funcName.
+// When the JS function is called, the first golang func matching the number and the
+// JS types of the arguments is called, those taking exactly the number of arguments
+// are tried before those with optional or variadic args. The trailing pointer or interface
+// args are optional as those of the other golang funcs, nil is passed if they are not given.
+func (ctx *JsContext) RegisterOverloads(funcName string, fns ...interface{}) (err error) {
+	if len(fns) == 0 {
+		err = fmt.Errorf("at least 1 func expected to register %s", funcName)
+		return
+	}
+	o := &goOverloads{name: funcName, fns: make([]reflect.Value, len(fns))}
+	length := -1
+	for i, fn := range fns {
+		fnVal := reflect.ValueOf(fn)
+		if fnVal.Kind() != reflect.Func {
+			err = fmt.Errorf("the #%d overload of %s is not a func", i, funcName)
+			return
+		}
+		o.fns[i] = fnVal
+		t := fnVal.Type()
+		if l := requiredArgsNum(t, injectedArgsNum(t), true); length < 0 || l < length {
+			length = l
+		}
+	}
+
+	ctx.lock()
+	defer ctx.unlock()
+
+	c := ctx.c
+	ptr := getPtrStore(uintptr(unsafe.Pointer(c)))
+	idx := ptr.register(o)
+	jsIdx := C.JS_NewUint32(c, C.uint32_t(idx))
+	defer C.JS_FreeValue(c, jsIdx)
+	fn := C.JS_NewCFunctionData(c, (*C.JSCFunctionData)(C.goOverloadBridge), C.int(length), 0, 1, (*C.JSValue)(unsafe.Pointer(&jsIdx)))
+
+	global := C.JS_GetGlobalObject(c)
+	defer C.JS_FreeValue(c, global)
+	cName := C.CString(funcName)
+	defer C.free(unsafe.Pointer(cName))
+	C.JS_SetPropertyStr(c, global, cName, fn)
+	return
+}
+
+//export goOverloadBridge
+func goOverloadBridge(ctx *C.JSContext, this_val C.JSValueConst, argc C.int, argv *C.JSValueConst, magic C.int, func_data *C.JSValue) (res C.JSValue) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = throwGoPanic(ctx, r)
+		}
+	}()
+
+	var jsIdx C.uint32_t
+	C.JS_ToUint32(ctx, &jsIdx, *func_data)
+	ptr := getPtrStore(uintptr(unsafe.Pointer(ctx)))
+	v, ok := ptr.lookup(uint32(jsIdx))
+	if !ok {
+		return C.toException()
+	}
+	o, ok := v.(*goOverloads)
+	if !ok {
+		return C.toException()
+	}
+
+	for _, exact := range []bool{true, false} {
+		for _, fnVal := range o.fns {
+			if matchGoFuncArgs(ctx, fnVal.Type(), argc, argv, exact) {
+				results, e := callGoFunc(ctx, fnVal, this_val, argc, argv, true)
+				return goFuncResult(ctx, results, e)
+			}
+		}
+	}
+	return throwGoError(ctx, fmt.Errorf("no overload of %s matches the arguments", o.name))
+}
+
+func matchGoFuncArgs(ctx *C.JSContext, fnType reflect.Type, argc C.int, argv *C.JSValueConst, exact bool) bool {
+	injected := injectedArgsNum(fnType)
+	variadic := fnType.IsVariadic()
+	fixedNum := fnType.NumIn() - injected
+	if variadic {
+		fixedNum -= 1
+	}
+	argsNum := int(argc)
+	if exact && (variadic || argsNum != fixedNum) {
+		return false
+	}
+	if argsNum < requiredArgsNum(fnType, injected, true) {
+		return false
+	}
+	if !variadic && argsNum > fixedNum {
+		if !takesCallInfo(fnType, injected) {
+			return false
+		}
+		argsNum = fixedNum
+	}
+
+	for i:=0; i<argsNum; i++ {
+		var t reflect.Type
+		if i < fixedNum {
+			t = fnType.In(injected + i)
+		} else {
+			t = fnType.In(fnType.NumIn() - 1).Elem()
+		}
+		if !jsTypeMatches(ctx, C.getOverloadArg(argv, C.int(i)), t) {
+			return false
+		}
+	}
+	return true
+}
+
+// check if a JS value can be converted to golang type t.
+func jsTypeMatches(ctx *C.JSContext, v C.JSValueConst, t reflect.Type) bool {
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		return true
+	}
+	switch {
+	case C.JS_IsUndefined(v) != 0 || C.JS_IsNull(v) != 0:
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func:
+			return true
+		}
+	case C.JS_IsBool(v) != 0:
+		return t.Kind() == reflect.Bool
+	case C.JS_IsNumber(v) != 0:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return true
+		}
+	case C.JS_IsString(v) != 0:
+		return t.Kind() == reflect.String || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8)
+	case C.JS_IsFunction(ctx, v) != 0:
+		return t.Kind() == reflect.Func
+	case C.JS_IsArray(ctx, v) != 0:
+		return t.Kind() == reflect.Slice || t.Kind() == reflect.Array
+	case C.JS_IsObject(v) != 0:
+		if isBytesType(t) {
+			if _, ok := getBufferBytes(ctx, v); ok {
+				return true
+			}
+		}
+		if goVal, ok := getTargetValue(ctx, v); ok {
+			if goVal == nil {
+				return false
+			}
+			gt := reflect.TypeOf(goVal)
+			return gt.AssignableTo(t) || (gt.Kind() == reflect.Ptr && gt.Elem().AssignableTo(t))
+		}
+		switch t.Kind() {
+		case reflect.Map, reflect.Struct:
+			return true
+		case reflect.Ptr:
+			return t.Elem().Kind() == reflect.Struct
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package quickjs
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testFormatOptions struct {
+	Width int
+}
+
+func TestRegisterOverloads(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   interface{}
+	}{
+		{"number", `format(1.5)`, "number 1.5"},
+		{"string", `format("a")`, "string a"},
+		{"optional pointer", `format("a", {width: 3})`, "string   a"},
+		{"null pointer", `format("a", null)`, "string a"},
+		{"bool", `format(true)`, "bool true"},
+		{"variadic", `format([1, 2], "x", "y")`, "list 2 x,y"},
+		{"variadic without args", `format([1])`, "list 1 "},
+		{"no match", `try { format(() => 1) } catch (e) { e.message }`, "no overload of format matches the arguments"},
+		{"length", `format.length`, int64(1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			err := ctx.RegisterOverloads("format",
+				func(n float64) string {
+					return fmt.Sprintf("number %v", n)
+				},
+				func(s string, opts *testFormatOptions) string {
+					if opts != nil {
+						return fmt.Sprintf("string %*s", opts.Width, s)
+					}
+					return "string " + s
+				},
+				func(b bool) string {
+					return fmt.Sprintf("bool %v", b)
+				},
+				func(list []int, tags ...string) string {
+					return fmt.Sprintf("list %d %s", len(list), strings.Join(tags, ","))
+				},
+			)
+			if err != nil {
+				t.Fatalf("RegisterOverloads: %v", err)
+			}
+			res, err := ctx.Eval(tt.script, nil)
+			if err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverloadsExactFirst(t *testing.T) {
+	ctx := newTestContext(t)
+	err := ctx.RegisterOverloads("f",
+		func(s string, opts *testFormatOptions) string { return "optional" },
+		func(s string) string { return "exact" },
+	)
+	if err != nil {
+		t.Fatalf("RegisterOverloads: %v", err)
+	}
+	res, err := ctx.Eval(`[f("a"), f("a", {})]`, nil)
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if want := []interface{}{"exact", "optional"}; !reflect.DeepEqual(res, want) {
+		t.Errorf("got %#v, want %#v", res, want)
+	}
+}
+
+func TestOverloadsBuffer(t *testing.T) {
+	ctx := newTestContext(t)
+	err := ctx.RegisterOverloads("size",
+		func(n int) string { return "number" },
+		func(b []byte) int { return len(b) },
+	)
+	if err != nil {
+		t.Fatalf("RegisterOverloads: %v", err)
+	}
+	res, err := ctx.Eval(`[size(new Uint8Array(3)), size(new ArrayBuffer(2)), size("a"), size(1)]`, nil)
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if want := []interface{}{int64(3), int64(2), int64(1), "number"}; !reflect.DeepEqual(res, want) {
+		t.Errorf("got %#v, want %#v", res, want)
+	}
+}
+
+func TestRegisterOverloadsErrors(t *testing.T) {
+	ctx := newTestContext(t)
+	if err := ctx.RegisterOverloads("f"); err == nil {
+		t.Errorf("no overload is expected to fail")
+	}
+	if err := ctx.RegisterOverloads("f", func() {}, 1); err == nil {
+		t.Errorf("a non-func overload is expected to fail")
+	}
+}
+
+func TestGoFuncArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      interface{}
+		script  string
+		want    interface{}
+		wantErr bool
+	}{
+		{"variadic", func(sep string, parts ...string) string { return strings.Join(parts, sep) }, `f("-", "a", "b")`, "a-b", false},
+		{"variadic without args", func(sep string, parts ...string) int { return len(parts) }, `f("-")`, int64(0), false},
+		{"missing arg", func(a, b int) int { return a + b }, `f(1)`, nil, true},
+		{"pointer optional", func(s string, opts *testFormatOptions) bool { return opts == nil }, `f("a")`, true, false},
+		{"pointer given", func(s string, opts *testFormatOptions) int { return opts.Width }, `f("a", {width: 3})`, int64(3), false},
+		{"interface optional", func(a int, v interface{}) bool { return v == nil }, `f(1)`, true, false},
+		{"only trailing optional", func(v interface{}, a int) int { return a }, `f(null)`, nil, true},
+		{"length", func(s string, opts *testFormatOptions, rest ...int) {}, `f.length`, int64(1), false},
+		{"extra args", func(a int) int { return a }, `f(1, 2)`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			res, err := ctx.Eval(tt.script, map[string]interface{}{"f": tt.fn})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package quickjs
+
+// #include "go-proxy.h"
+import "C"
+import (
+	"runtime/debug"
+	"fmt"
+)
+
+// GoPanicError is returned by Eval, CallFunc and so on, when a golang func
+// or a golang value accessed by JS panics.
+type GoPanicError struct {
+	Value interface{} // the value passed to panic()
+	Stack []byte      // the golang stack when panicking
+}
+
+func (e *GoPanicError) Error() string {
+	return fmt.Sprintf("golang panic: %v", e.Value)
+}
+
+// throwGoPanic must be called in the deferred func of the exported callbacks
+// with the result of recover(), so the panic doesn't unwind through C frames.
+func throwGoPanic(ctx *C.JSContext, r interface{}) C.JSValue {
+	return C.JS_Throw(ctx, newGoPanicError(ctx, &GoPanicError{Value: r, Stack: debug.Stack()}))
+}
+
+func newGoPanicError(ctx *C.JSContext, e *GoPanicError) C.JSValue {
+	errObj := newGoError(ctx, e)
+	setPropertyStr(ctx, errObj, "goStack\x00", makeBytes(ctx, e.Stack))
+	return errObj
+}
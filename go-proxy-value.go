@@ -20,6 +20,19 @@ func makeJsValue(ctx *C.JSContext, v interface{}) (C.JSValue, error) {
 		return C.toNull(), nil
 	}
 
+	switch vv := v.(type) {
+	case JsValue:
+		return C.JS_DupValue(ctx, vv.v), nil
+	case HostObject:
+		return makeGoObject(ctx, v), nil
+	case AsyncFunc:
+		return bindAsyncGoFunc(ctx, vv.fn)
+	case Uint8Array:
+		return makeUint8Array(ctx, vv), nil
+	case IOStream:
+		return makeStream(ctx, vv)
+	}
+
 	vv := reflect.ValueOf(v)
 	switch vv.Kind() {
 	case reflect.Bool:
@@ -56,12 +69,17 @@ func makeJsValue(ctx *C.JSContext, v interface{}) (C.JSValue, error) {
 	case reflect.Array, reflect.Map, reflect.Struct, reflect.Interface:
 		return makeGoObject(ctx, v), nil
 	case reflect.Ptr:
+		if vv.IsNil() {
+			return C.toNull(), nil
+		}
 		if vv.Elem().Kind() == reflect.Struct {
 			return makeGoObject(ctx, v), nil
 		}
 		return makeJsValue(ctx, vv.Elem().Interface())
 	case reflect.Func:
 		return bindGoFunc(ctx, v), nil
+	case reflect.Chan:
+		return makeChannel(ctx, vv)
 	default:
 		return C.toUndefined(), fmt.Errorf("unsupported type %v", vv.Kind())
 	}
@@ -218,6 +236,10 @@ func getTargetIdx(ctx *C.JSContext, obj C.JSValueConst) (idx uint32) {
 
 func getTargetValue(ctx *C.JSContext, obj C.JSValueConst) (v interface{}, ok bool) {
 	idx := getTargetIdx(ctx, obj)
+	if idx == 0 {
+		// not a Go object
+		return
+	}
 
 	ptr := getPtrStore(uintptr(unsafe.Pointer(ctx)))
 	vPtr, o := ptr.lookup(idx)
@@ -248,13 +270,95 @@ func getKeyName(ctx *C.JSContext, atom C.JSAtom) (key string) {
 }
 
 //export goObjHas
-func goObjHas(ctx *C.JSContext, obj C.JSValueConst, atom C.JSAtom) C.int {
+func goObjHas(ctx *C.JSContext, obj C.JSValueConst, atom C.JSAtom) (res C.int) {
+	defer func() {
+		if r := recover(); r != nil {
+			throwGoPanic(ctx, r)
+			res = -1
+		}
+	}()
+
 	// fmt.Printf("-- goObjHas called\n")
+	v, ok := getTargetValue(ctx, obj)
+	if !ok {
+		return 0
+	}
+	if h, ok := v.(HostObject); ok {
+		if h.Has(getKeyName(ctx, atom)) {
+			return 1
+		}
+	}
 	return 0;
 }
 
+//export goObjGetOwnProperty
+func goObjGetOwnProperty(ctx *C.JSContext, desc *C.JSPropertyDescriptor, obj C.JSValueConst, atom C.JSAtom) (res C.int) {
+	defer func() {
+		if r := recover(); r != nil {
+			throwGoPanic(ctx, r)
+			res = -1
+		}
+	}()
+
+	v, ok := getTargetValue(ctx, obj)
+	if !ok {
+		return 0
+	}
+	if h, ok := v.(HostObject); ok {
+		return go_host_own_property(ctx, desc, h, getKeyName(ctx, atom))
+	}
+	return 0
+}
+
+//export goObjGetOwnPropertyNames
+func goObjGetOwnPropertyNames(ctx *C.JSContext, ptab **C.JSPropertyEnum, plen *C.uint32_t, obj C.JSValueConst) (res C.int) {
+	defer func() {
+		if r := recover(); r != nil {
+			throwGoPanic(ctx, r)
+			res = -1
+		}
+	}()
+
+	*ptab, *plen = nil, 0
+	v, ok := getTargetValue(ctx, obj)
+	if !ok {
+		return 0
+	}
+	if h, ok := v.(HostObject); ok {
+		return go_host_own_property_names(ctx, ptab, plen, h)
+	}
+	return 0
+}
+
+//export goObjDelete
+func goObjDelete(ctx *C.JSContext, obj C.JSValueConst, atom C.JSAtom) (res C.int) {
+	defer func() {
+		if r := recover(); r != nil {
+			throwGoPanic(ctx, r)
+			res = -1
+		}
+	}()
+
+	v, ok := getTargetValue(ctx, obj)
+	if !ok {
+		return 0
+	}
+	if h, ok := v.(HostObject); ok {
+		if h.Delete(getKeyName(ctx, atom)) {
+			return 1
+		}
+	}
+	return 0
+}
+
 //export goObjGet
-func goObjGet(ctx *C.JSContext, obj C.JSValueConst, atom C.JSAtom, receiver C.JSValueConst) C.JSValue {
+func goObjGet(ctx *C.JSContext, obj C.JSValueConst, atom C.JSAtom, receiver C.JSValueConst) (res C.JSValue) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = throwGoPanic(ctx, r)
+		}
+	}()
+
 	// fmt.Printf("--- getTargetValue called\n")
 	v, ok := getTargetValue(ctx, obj)
 	if !ok {
@@ -267,12 +371,18 @@ func goObjGet(ctx *C.JSContext, obj C.JSValueConst, atom C.JSAtom, receiver C.JS
 	if len(key) == 0 {
 		return C.toUndefined()
 	}
+	if h, ok := v.(HostObject); ok {
+		return go_host_get(ctx, h, key)
+	}
 	switch vv := reflect.ValueOf(v); vv.Kind() {
 	case reflect.Slice, reflect.Array:
 		return go_arr_get(ctx, vv, key)
 	case reflect.Map:
 		return go_map_get(ctx, vv, key)
 	case reflect.Struct, reflect.Ptr:
+		if findGoClass(ctx, vv.Type()) != nil {
+			return go_class_get(ctx, obj, vv, atom, key, receiver)
+		}
 		return go_struct_get(ctx, vv, key)
 	case reflect.Interface:
 		return go_interface_get(ctx, vv, key)
@@ -284,7 +394,14 @@ func goObjGet(ctx *C.JSContext, obj C.JSValueConst, atom C.JSAtom, receiver C.JS
 /* return < 0 if exception or TRUE/FALSE */
 
 //export goObjSet
-func goObjSet(ctx *C.JSContext, obj C.JSValueConst, atom C.JSAtom, value C.JSValueConst, receiver C.JSValueConst, flags C.int) C.int {
+func goObjSet(ctx *C.JSContext, obj C.JSValueConst, atom C.JSAtom, value C.JSValueConst, receiver C.JSValueConst, flags C.int) (res C.int) {
+	defer func() {
+		if r := recover(); r != nil {
+			throwGoPanic(ctx, r)
+			res = -1
+		}
+	}()
+
 	v, ok := getTargetValue(ctx, obj)
 	if !ok {
 		return 0
@@ -296,6 +413,9 @@ func goObjSet(ctx *C.JSContext, obj C.JSValueConst, atom C.JSAtom, value C.JSVal
 	if len(key) == 0 {
 		return 0
 	}
+	if h, ok := v.(HostObject); ok {
+		return go_host_set(ctx, h, key, value)
+	}
 	switch vv := reflect.ValueOf(v); vv.Kind() {
 	case reflect.Slice, reflect.Array:
 		return go_arr_set(ctx, vv, key, value)
@@ -310,6 +430,15 @@ func goObjSet(ctx *C.JSContext, obj C.JSValueConst, atom C.JSAtom, value C.JSVal
 
 //export goFreeId
 func goFreeId(ctx *C.JSContext, idx C.uint32_t) {
+	defer func() {
+		// called by finalizer, nothing can be thrown
+		recover()
+	}()
+
+	if getJsContext(ctx) == nil {
+		// the context is closed and its store is deleted, the objects left are freed with the runtime
+		return
+	}
 	ptr := getPtrStore(uintptr(unsafe.Pointer(ctx)))
 	ptr.remove(uint32(idx))
 }
@@ -317,6 +446,9 @@ func goFreeId(ctx *C.JSContext, idx C.uint32_t) {
 func makeGoObject(ctx *C.JSContext, v interface{}) C.JSValue {
 	ptr := getPtrStore(uintptr(unsafe.Pointer(ctx)))
 	idx := ptr.register(&v)
+	if cls := findGoClass(ctx, reflect.TypeOf(v)); cls != nil {
+		return C.makeGoObjectWithProto(ctx, C.uint32_t(idx), cls.proto)
+	}
 	return C.makeGoObject(ctx, C.uint32_t(idx))
 }
 
@@ -5,6 +5,9 @@ extern int goObjHas(JSContext *ctx, JSValueConst obj, JSAtom atom);
 extern JSValue goObjGet(JSContext *ctx, JSValueConst obj, JSAtom atom, JSValueConst receiver);
 extern int goObjSet(JSContext *ctx, JSValueConst obj, JSAtom atom, JSValueConst value, JSValueConst receiver, int flags);
 extern void goFreeId(JSContext *ctx, uint32_t idx);
+extern int goObjGetOwnProperty(JSContext *ctx, JSPropertyDescriptor *desc, JSValueConst obj, JSAtom prop);
+extern int goObjGetOwnPropertyNames(JSContext *ctx, JSPropertyEnum **ptab, uint32_t *plen, JSValueConst obj);
+extern int goObjDelete(JSContext *ctx, JSValueConst obj, JSAtom prop);
 
 typedef struct {
 	JSContext *ctx;
@@ -20,14 +23,14 @@ static void free_obj_id_opaque(JSRuntime* rt, JSValue val) {
 	}
 	goFreeId(o->ctx, o->idx);
 	free(o);
-	JS_FreeValueRT(rt, val);
+	// val is being freed, it must not be released again
 }
 
 static JSClassExoticMethods go_obj_handler_exotic_methods = {
-    .get_own_property = NULL,
+    .get_own_property = goObjGetOwnProperty,
     .define_own_property = NULL,
-    .delete_property = NULL,
-    .get_own_property_names = NULL,
+    .delete_property = goObjDelete,
+    .get_own_property_names = goObjGetOwnPropertyNames,
     .has_property = goObjHas,
     .get_property = goObjGet,
     .set_property = goObjSet,
@@ -67,8 +70,8 @@ static void setGoObjOpaque(JSContext *ctx, JSValue val, uint32_t idx) {
 	JS_SetOpaque(val, o);
 }
 
-JSValue makeGoObject(JSContext *ctx, uint32_t idx) {
-	JSValue goObj = JS_NewObjectProtoClass(ctx, JS_NULL, goObjClassId);
+JSValue makeGoObjectWithProto(JSContext *ctx, uint32_t idx, JSValueConst proto) {
+	JSValue goObj = JS_NewObjectProtoClass(ctx, proto, goObjClassId);
 	if (JS_IsException(goObj)) {
 		return goObj;
 	}
@@ -76,6 +79,10 @@ JSValue makeGoObject(JSContext *ctx, uint32_t idx) {
 	return goObj;
 }
 
+JSValue makeGoObject(JSContext *ctx, uint32_t idx) {
+	return makeGoObjectWithProto(ctx, idx, JS_NULL);
+}
+
 int restoreGoObjIdx(JSValue val, uint32_t *idx, JSContext **ctx) {
 	goOpaque *o = (goOpaque*)JS_GetOpaque(val, goObjClassId);
 	if (o == NULL) {
@@ -90,6 +97,26 @@ int restoreGoObjIdx(JSValue val, uint32_t *idx, JSContext **ctx) {
 	return 1;
 }
 
+JSPropertyEnum *allocPropEnum(JSContext *ctx, uint32_t len) {
+	return (JSPropertyEnum*)js_mallocz(ctx, sizeof(JSPropertyEnum) * (len > 0 ? len : 1));
+}
+
+void setPropEnum(JSPropertyEnum *tab, uint32_t i, JSAtom atom) {
+	tab[i].is_enumerable = 1;
+	tab[i].atom = atom;
+}
+
+void setPropDesc(JSContext *ctx, JSPropertyDescriptor *desc, JSValue val) {
+	if (desc == NULL) {
+		JS_FreeValue(ctx, val);
+		return;
+	}
+	desc->flags = JS_PROP_ENUMERABLE | JS_PROP_WRITABLE | JS_PROP_CONFIGURABLE;
+	desc->value = val;
+	desc->getter = JS_UNDEFINED;
+	desc->setter = JS_UNDEFINED;
+}
+
 JSValue toException() {
 	return JS_EXCEPTION;
 }
@@ -109,3 +136,46 @@ JSValue toTrue() {
 JSValue toFalse() {
 	return JS_FALSE;
 }
+
+/* the module of the function value compiled with JS_EVAL_FLAG_COMPILE_ONLY */
+JSModuleDef *moduleOf(JSValueConst func_val) {
+	return JS_VALUE_GET_PTR(func_val);
+}
+
+/* clone v by serializing it, the object references are kept */
+JSValue cloneValue(JSContext *ctx, JSValueConst v) {
+	size_t len;
+	uint8_t *buf = JS_WriteObject(ctx, &len, v, JS_WRITE_OBJ_REFERENCE);
+	if (buf == NULL) {
+		return JS_EXCEPTION;
+	}
+	JSValue res = JS_ReadObject(ctx, buf, len, JS_READ_OBJ_REFERENCE);
+	js_free(ctx, buf);
+	return res;
+}
+
+static JSValue structuredClone(JSContext *ctx, JSValueConst this_val, int argc, JSValueConst *argv) {
+	return cloneValue(ctx, argc > 0 ? argv[0] : JS_UNDEFINED);
+}
+
+JSValue newStructuredClone(JSContext *ctx) {
+	return JS_NewCFunction(ctx, structuredClone, "structuredClone", 1);
+}
+
+static void freeSerialized(JSRuntime *rt, void *opaque, void *ptr) {
+	js_free_rt(rt, ptr);
+}
+
+// write a value with the structured serialization to an ArrayBuffer, which is read by JS_ReadObject.
+static JSValue serialize(JSContext *ctx, JSValueConst this_val, int argc, JSValueConst *argv) {
+	size_t len;
+	uint8_t *buf = JS_WriteObject(ctx, &len, argc > 0 ? argv[0] : JS_UNDEFINED, 0);
+	if (buf == NULL) {
+		return JS_EXCEPTION;
+	}
+	return JS_NewArrayBuffer(ctx, buf, len, freeSerialized, NULL, 0);
+}
+
+JSValue newSerializer(JSContext *ctx) {
+	return JS_NewCFunction(ctx, serialize, "serialize", 1);
+}
@@ -9,4 +9,14 @@ JSValue toNull();
 JSValue toTrue();
 JSValue toFalse();
 
+JSValue makeGoObjectWithProto(JSContext *ctx, uint32_t idx, JSValueConst proto);
+JSPropertyEnum *allocPropEnum(JSContext *ctx, uint32_t len);
+void setPropEnum(JSPropertyEnum *tab, uint32_t i, JSAtom atom);
+void setPropDesc(JSContext *ctx, JSPropertyDescriptor *desc, JSValue val);
+JSModuleDef *moduleOf(JSValueConst func_val);
+
+JSValue cloneValue(JSContext *ctx, JSValueConst v);
+JSValue newStructuredClone(JSContext *ctx);
+JSValue newSerializer(JSContext *ctx);
+
 #endif
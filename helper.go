@@ -1,6 +1,9 @@
 package quickjs
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
 	"sync"
 	"os"
 	"time"
@@ -9,10 +12,16 @@ import (
 type jsCtx struct {
 	jsvm *JsContext
 	mt   time.Time
+	loader ModuleLoader // the loader of the modules imported
+	deps map[string]string // the modules imported, with their stamps
+	refs int  // number of users acquiring the context
+	stale bool // replaced by a reloaded one
+	untracked bool // returned by LoadFileFromCache, left to the garbage collector
 }
 
 var (
 	jsCtxCache map[string]*jsCtx
+	cacheLoader ModuleLoader
 	lock *sync.Mutex
 )
 
@@ -24,48 +33,158 @@ func InitCache() {
 	jsCtxCache = make(map[string]*jsCtx)
 }
 
+// SetCacheModuleLoader sets the ModuleLoader of the contexts created by LoadFileFromCache
+// and AcquireFileFromCache afterwards, the modules loaded by it are checked for the
+// modification too. A nil loader restores the default one.
+func SetCacheModuleLoader(loader ModuleLoader) {
+	lock.Lock()
+	defer lock.Unlock()
+	cacheLoader = loader
+}
+
+// LoadFileFromCache returns the context with the script file evaluated, which is
+// reloaded if the file or any module imported by it is modified. The context replaced
+// by a reloaded one is left to the garbage collector.
 func LoadFileFromCache(path string, vars map[string]interface{}) (ctx *JsContext, existing bool, err error) {
 	lock.Lock()
 	defer lock.Unlock()
 
+	jsC, existing, err := loadFileFromCache(path, vars)
+	if err != nil {
+		return
+	}
+	jsC.untracked = true
+	ctx = jsC.jsvm
+	return
+}
+
+// AcquireFileFromCache is same as LoadFileFromCache, but the context is reference
+// counted: release must be called when the caller is done with it. A context replaced
+// by a reloaded one is kept until all of its users release it, and then it's closed.
+func AcquireFileFromCache(path string, vars map[string]interface{}) (ctx *JsContext, release func(), existing bool, err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	jsC, existing, err := loadFileFromCache(path, vars)
+	if err != nil {
+		return
+	}
+	jsC.refs += 1
+	ctx = jsC.jsvm
+
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			lock.Lock()
+			defer lock.Unlock()
+
+			jsC.refs -= 1
+			jsC.closeIfIdle()
+		})
+	}
+	return
+}
+
+func loadFileFromCache(path string, vars map[string]interface{}) (jsC *jsCtx, existing bool, err error) {
 	jsC, ok := jsCtxCache[path]
+	if ok && !jsC.modified(path) {
+		existing = true
+		return
+	}
 
-	if !ok {
-		if ctx, err = createJSContext(path, vars); err != nil {
-			return
-		}
-		fi, _ := os.Stat(path)
-		jsC = &jsCtx{
-			jsvm: ctx,
-			mt: fi.ModTime(),
-		}
-		jsCtxCache[path] = jsC
+	newC, e := createCachedContext(path, vars)
+	if e != nil {
+		err = e
 		return
 	}
+	if ok {
+		// the old one is closed once it's idle
+		jsC.stale = true
+		jsC.closeIfIdle()
+	}
+	jsCtxCache[path] = newC
+	jsC = newC
+	return
+}
 
+func createCachedContext(path string, vars map[string]interface{}) (jsC *jsCtx, err error) {
 	fi, e := os.Stat(path)
 	if e != nil {
 		err = e
 		return
 	}
-	mt := fi.ModTime()
-	if !jsC.mt.Equal(mt) {
-		if ctx, err = createJSContext(path, vars); err != nil {
-			return
+	ctx, e := createJSContext(path, vars, cacheLoader)
+	if e != nil {
+		err = e
+		return
+	}
+	jsC = &jsCtx{
+		jsvm: ctx,
+		mt: fi.ModTime(),
+		loader: cacheLoader,
+		deps: make(map[string]string),
+	}
+	for _, dep := range ctx.deps {
+		if stamp, e := moduleStamp(cacheLoader, dep); e == nil {
+			jsC.deps[dep] = stamp
 		}
-		jsC.jsvm = ctx
-		jsC.mt = mt
-	} else {
-		existing = true
-		ctx = jsC.jsvm
 	}
 	return
 }
 
-func createJSContext(path string, vars map[string]interface{}) (ctx *JsContext, err error) {
+// check the mtime of the script file and the stamps of all the modules imported by it.
+func (jsC *jsCtx) modified(path string) bool {
+	fi, e := os.Stat(path)
+	if e != nil || !jsC.mt.Equal(fi.ModTime()) {
+		return true
+	}
+	for dep, stamp := range jsC.deps {
+		if s, e := moduleStamp(jsC.loader, dep); e != nil || s != stamp {
+			return true
+		}
+	}
+	return false
+}
+
+// the stamp of a module, which is changed when the module is modified. It's the mtime of
+// the file, or the digest of the source if the loader doesn't tell the mtime.
+func moduleStamp(loader ModuleLoader, moduleName string) (stamp string, err error) {
+	switch l := loader.(type) {
+	case nil:
+		fi, e := os.Stat(moduleName)
+		if e != nil {
+			err = e
+			return
+		}
+		return fi.ModTime().Format(time.RFC3339Nano), nil
+	case *fsModuleLoader:
+		// the files of embed.FS are without mtime
+		if fi, e := fs.Stat(l.fsys, moduleName); e == nil && !fi.ModTime().IsZero() {
+			return fi.ModTime().Format(time.RFC3339Nano), nil
+		}
+	}
+	source, e := loader.Load(moduleName)
+	if e != nil {
+		err = e
+		return
+	}
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (jsC *jsCtx) closeIfIdle() {
+	if jsC.stale && jsC.refs == 0 && !jsC.untracked {
+		jsC.jsvm.Close()
+	}
+}
+
+func createJSContext(path string, vars map[string]interface{}, loader ModuleLoader) (ctx *JsContext, err error) {
 	if ctx, err = NewContext(); err != nil {
 		return
 	}
+	if loader != nil {
+		ctx.SetModuleLoader(loader)
+	}
 	if _, err = ctx.EvalFile(path, vars); err != nil {
 		return
 	}
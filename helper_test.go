@@ -0,0 +1,235 @@
+package quickjs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// write the files of a script importing a module, and return the path of the script.
+func writeCachedScript(t *testing.T, dep string) string {
+	t.Helper()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "main.js")
+	files := map[string]string{
+		script: `import {v} from "./dep.js"; globalThis.v = v;`,
+		filepath.Join(dir, "dep.js"): dep,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return script
+}
+
+// set the mtime of the file to a later time, so it's taken as modified.
+func touch(t *testing.T, path string, n int) {
+	t.Helper()
+	mt := time.Now().Add(time.Duration(n) * time.Hour)
+	if err := os.Chtimes(path, mt, mt); err != nil {
+		t.Fatalf("failed to touch %s: %v", path, err)
+	}
+}
+
+func TestLoadFileFromCache(t *testing.T) {
+	InitCache()
+
+	tests := []struct {
+		name   string
+		modify string // the file modified before the second load
+		reload bool
+	}{
+		{name: "unmodified"},
+		{name: "script modified", modify: "main.js", reload: true},
+		{name: "module modified", modify: "dep.js", reload: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script := writeCachedScript(t, `export const v = 1;`)
+			ctx1, existing, err := LoadFileFromCache(script, nil)
+			if err != nil {
+				t.Fatalf("failed to load: %v", err)
+			}
+			if existing {
+				t.Fatalf("the first load got an existing context")
+			}
+
+			if tt.modify != "" {
+				path := filepath.Join(filepath.Dir(script), tt.modify)
+				if tt.modify == "dep.js" {
+					if err := os.WriteFile(path, []byte(`export const v = 2;`), 0644); err != nil {
+						t.Fatalf("failed to write %s: %v", path, err)
+					}
+				}
+				touch(t, path, 1)
+			}
+			ctx2, existing, err := LoadFileFromCache(script, nil)
+			if err != nil {
+				t.Fatalf("failed to load again: %v", err)
+			}
+			if existing == tt.reload || (ctx1 == ctx2) == tt.reload {
+				t.Fatalf("reload expected %v, but existing is %v", tt.reload, existing)
+			}
+
+			// the stale context is left to the garbage collector, it's still usable
+			if _, err := ctx1.Eval("v", nil); err != nil {
+				t.Fatalf("the stale context is not usable: %v", err)
+			}
+			want := int64(1)
+			if tt.modify == "dep.js" {
+				want = 2
+			}
+			if res, err := ctx2.Eval("v", nil); err != nil || res != want {
+				t.Fatalf("v expected %v, but got %v, %v", want, res, err)
+			}
+		})
+	}
+}
+
+func TestLoadFileFromCacheWithLoader(t *testing.T) {
+	InitCache()
+	defer SetCacheModuleLoader(nil)
+
+	mt := time.Now()
+	tests := []struct {
+		name   string
+		loader func() (loader ModuleLoader, modify func())
+		reload bool
+	}{
+		{"map unmodified", func() (ModuleLoader, func()) {
+			return NewMapModuleLoader(map[string]string{"dep.js": `export const v = 1;`}), func() {}
+		}, false},
+		{"map", func() (ModuleLoader, func()) {
+			m := map[string]string{"dep.js": `export const v = 1;`}
+			return NewMapModuleLoader(m), func() { m["dep.js"] = `export const v = 2;` }
+		}, true},
+		{"fs with mtime", func() (ModuleLoader, func()) {
+			fsys := fstest.MapFS{"dep.js": {Data: []byte(`export const v = 1;`), ModTime: mt}}
+			return NewFSModuleLoader(fsys), func() {
+				fsys["dep.js"] = &fstest.MapFile{Data: []byte(`export const v = 2;`), ModTime: mt.Add(time.Hour)}
+			}
+		}, true},
+		{"fs without mtime", func() (ModuleLoader, func()) {
+			fsys := fstest.MapFS{"dep.js": {Data: []byte(`export const v = 1;`)}}
+			return NewFSModuleLoader(fsys), func() { fsys["dep.js"] = &fstest.MapFile{Data: []byte(`export const v = 2;`)} }
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script := filepath.Join(t.TempDir(), "main.js")
+			if err := os.WriteFile(script, []byte(`import {v} from "dep.js"; globalThis.v = v;`), 0644); err != nil {
+				t.Fatalf("failed to write %s: %v", script, err)
+			}
+			loader, modify := tt.loader()
+			SetCacheModuleLoader(loader)
+
+			if _, _, err := LoadFileFromCache(script, nil); err != nil {
+				t.Fatalf("failed to load: %v", err)
+			}
+			modify()
+			ctx, existing, err := LoadFileFromCache(script, nil)
+			if err != nil {
+				t.Fatalf("failed to load again: %v", err)
+			}
+			if existing == tt.reload {
+				t.Fatalf("reload expected %v, but existing is %v", tt.reload, existing)
+			}
+			want := int64(1)
+			if tt.reload {
+				want = 2
+			}
+			if res, err := ctx.Eval("v", nil); err != nil || res != want {
+				t.Fatalf("v expected %v, but got %v, %v", want, res, err)
+			}
+		})
+	}
+}
+
+// a module imported more than once, such as a JSON file imported as text too, is recorded once.
+func TestModuleDeps(t *testing.T) {
+	ctx := newTestContext(t)
+	ctx.SetModuleLoader(NewMapModuleLoader(map[string]string{
+		"a.js":   `import "c.json"; import "b.js";`,
+		"b.js":   `import "c.json" with {type: "text"};`,
+		"c.json": `{}`,
+	}))
+	if _, err := ctx.EvalModule("main.js", `import "a.js"; import "c.json";`); err != nil {
+		t.Fatalf("EvalModule: %v", err)
+	}
+	if want := []string{"a.js", "c.json", "b.js"}; !reflect.DeepEqual(ctx.deps, want) {
+		t.Errorf("got deps %v, want %v", ctx.deps, want)
+	}
+}
+
+func TestAcquireFileFromCache(t *testing.T) {
+	InitCache()
+
+	script := writeCachedScript(t, `export const v = 1;`)
+	ctx1, release1, _, err := AcquireFileFromCache(script, nil)
+	if err != nil {
+		t.Fatalf("failed to acquire: %v", err)
+	}
+	_, release2, existing, err := AcquireFileFromCache(script, nil)
+	if err != nil || !existing {
+		t.Fatalf("existing context expected, but got %v, %v", existing, err)
+	}
+	touch(t, script, 1)
+	_, release3, existing, err := AcquireFileFromCache(script, nil)
+	if err != nil || existing {
+		t.Fatalf("reloaded context expected, but got %v, %v", existing, err)
+	}
+	defer release3()
+
+	// releasing more than once is same as once, so the stale context is kept for release2
+	release1()
+	release1()
+	if ctx1.c == nil {
+		t.Fatalf("the stale context is closed before all users release it")
+	}
+	if _, err := ctx1.Eval("v", nil); err != nil {
+		t.Fatalf("the context is not usable before released: %v", err)
+	}
+	release2()
+	if ctx1.c != nil {
+		t.Fatalf("the stale context is not closed after released")
+	}
+}
+
+// a context returned by LoadFileFromCache may still be used by the caller, so it's not closed
+// when it's replaced, even if all of the users acquiring it release it.
+func TestLoadFileFromCacheNotClosed(t *testing.T) {
+	InitCache()
+
+	script := writeCachedScript(t, `export const v = 1;`)
+	ctx1, release, _, err := AcquireFileFromCache(script, nil)
+	if err != nil {
+		t.Fatalf("failed to acquire: %v", err)
+	}
+	if _, _, err = LoadFileFromCache(script, nil); err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	touch(t, script, 1)
+	if _, _, err = LoadFileFromCache(script, nil); err != nil {
+		t.Fatalf("failed to load again: %v", err)
+	}
+	release()
+	if ctx1.c == nil {
+		t.Fatalf("the context returned by LoadFileFromCache is closed")
+	}
+}
+
+func TestLoadFileFromCacheError(t *testing.T) {
+	InitCache()
+
+	missing := filepath.Join(t.TempDir(), "none.js")
+	if _, _, err := LoadFileFromCache(missing, nil); err == nil {
+		t.Errorf("LoadFileFromCache: error expected for a missing file")
+	}
+	if _, _, _, err := AcquireFileFromCache(missing, nil); err == nil {
+		t.Errorf("AcquireFileFromCache: error expected for a missing file")
+	}
+}
@@ -0,0 +1,74 @@
+package quickjs
+
+// #include "go-proxy.h"
+import "C"
+
+// HostObject is implemented by a golang value whose properties are computed
+// dynamically. Putting it in env or returning it from a golang function, all
+// property accesses of the JS object are dispatched to it.
+type HostObject interface {
+	// Get returns the value of key, ok is false if key doesn't exist.
+	Get(key string) (v interface{}, ok bool)
+	// Set sets the value of key, the error will be thrown in JS.
+	Set(key string, v interface{}) error
+	// Has reports whether key exists, used by operator `in`.
+	Has(key string) bool
+	// Keys lists all keys, used by `Object.keys()`, `for ... in` and so on.
+	Keys() []string
+	// Delete removes key, used by operator `delete`.
+	Delete(key string) bool
+}
+
+func go_host_get(ctx *C.JSContext, h HostObject, key string) C.JSValue {
+	v, ok := h.Get(key)
+	if !ok {
+		return C.toUndefined()
+	}
+	jsVal, err := makeJsValue(ctx, v)
+	if err != nil {
+		return throwGoError(ctx, err)
+	}
+	return jsVal
+}
+
+func go_host_set(ctx *C.JSContext, h HostObject, key string, value C.JSValueConst) C.int {
+	goVal, err := fromJsValue(ctx, value)
+	if err != nil {
+		return 0
+	}
+	if err = h.Set(key, goVal); err != nil {
+		throwGoError(ctx, err)
+		return -1
+	}
+	return 1
+}
+
+func go_host_own_property(ctx *C.JSContext, desc *C.JSPropertyDescriptor, h HostObject, key string) C.int {
+	v, ok := h.Get(key)
+	if !ok {
+		return 0
+	}
+	jsVal, err := makeJsValue(ctx, v)
+	if err != nil {
+		throwGoError(ctx, err)
+		return -1
+	}
+	C.setPropDesc(ctx, desc, jsVal)
+	return 1
+}
+
+func go_host_own_property_names(ctx *C.JSContext, ptab **C.JSPropertyEnum, plen *C.uint32_t, h HostObject) C.int {
+	keys := h.Keys()
+	tab := C.allocPropEnum(ctx, C.uint32_t(len(keys)))
+	if tab == nil {
+		return -1
+	}
+	for i, key := range keys {
+		var cstr *C.char
+		var l C.int
+		getStrPtrLen(&key, &cstr, &l)
+		C.setPropEnum(tab, C.uint32_t(i), C.JS_NewAtomLen(ctx, cstr, C.size_t(l)))
+	}
+	*ptab, *plen = tab, C.uint32_t(len(keys))
+	return 0
+}
@@ -0,0 +1,110 @@
+package quickjs
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// a HostObject keeping the values in a map, the keys starting with "_" are read-only.
+type testHost struct {
+	values map[string]interface{}
+	gets   int
+}
+
+func (h *testHost) Get(key string) (interface{}, bool) {
+	h.gets++
+	v, ok := h.values[key]
+	return v, ok
+}
+
+func (h *testHost) Set(key string, v interface{}) error {
+	if key[0] == '_' {
+		return fmt.Errorf("%s is read-only", key)
+	}
+	h.values[key] = v
+	return nil
+}
+
+func (h *testHost) Has(key string) bool {
+	_, ok := h.values[key]
+	return ok
+}
+
+func (h *testHost) Keys() []string {
+	keys := make([]string, 0, len(h.values))
+	for k := range h.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (h *testHost) Delete(key string) bool {
+	delete(h.values, key)
+	return true
+}
+
+func TestHostObject(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   interface{}
+		values map[string]interface{} // the values of the host object after the script
+	}{
+		{"get", `h.a`, int64(1), nil},
+		{"missing", `h.missing`, nil, nil},
+		{"nested", `h.nested.b`, "x", nil},
+		{"func", `h.add(1, 2)`, int64(3), nil},
+		{"set", `h.c = 3; h.c`, int64(3), map[string]interface{}{"c": int64(3)}},
+		{"set error", `try { h._ro = 1 } catch (e) { e.message }`, "_ro is read-only", nil},
+		{"in", `["a" in h, "missing" in h]`, []interface{}{true, false}, nil},
+		{"keys", `Object.keys(h)`, []interface{}{"_ro", "a", "add", "nested"}, nil},
+		{"for in", `const keys = []; for (const k in h) keys.push(k); keys.join()`, "_ro,a,add,nested", nil},
+		{"delete", `delete h.a; "a" in h`, false, nil},
+		{"passed back", `same(h)`, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			h := &testHost{values: map[string]interface{}{
+				"a": 1,
+				"_ro": 0,
+				"nested": map[string]interface{}{"b": "x"},
+				"add": func(a, b int) int { return a + b },
+			}}
+			same := func(v interface{}) bool {
+				return v == h
+			}
+			res, err := ctx.Eval(tt.script, map[string]interface{}{"h": HostObject(h), "same": same})
+			if err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+			for k, v := range tt.values {
+				if !reflect.DeepEqual(h.values[k], v) {
+					t.Errorf("got %s = %#v, want %#v", k, h.values[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestHostObjectIsLazy(t *testing.T) {
+	ctx := newTestContext(t)
+	h := &testHost{values: map[string]interface{}{"a": 1}}
+	if _, err := ctx.Eval(`h.a; h.a`, map[string]interface{}{"h": HostObject(h)}); err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	h.values["a"] = 2
+	res, err := ctx.Eval(`h.a`, nil)
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if res != int64(2) || h.gets != 3 {
+		t.Errorf("got %v after %d gets", res, h.gets)
+	}
+}
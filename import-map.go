@@ -0,0 +1,79 @@
+package quickjs
+
+import (
+	"encoding/json"
+	"strings"
+	"sort"
+)
+
+// ImportMap maps the module specifiers imported by scripts to module names, in the
+// format of the import maps of browsers. A key ending with "/" maps all the specifiers
+// with the prefix. Scopes are the mappings used by the modules whose names start with
+// the scope keys, which are preferred to Imports.
+type ImportMap struct {
+	Imports map[string]string            `json:"imports"`
+	Scopes  map[string]map[string]string `json:"scopes"`
+}
+
+// ParseImportMap parses the JSON of an import map.
+func ParseImportMap(b []byte) (m *ImportMap, err error) {
+	var im ImportMap
+	if err = json.Unmarshal(b, &im); err != nil {
+		return
+	}
+	m = &im
+	return
+}
+
+// SetImportMap sets the import map used to resolve the modules imported by scripts,
+// nil to remove it.
+func (ctx *JsContext) SetImportMap(m *ImportMap) {
+	ctx.lock()
+	defer ctx.unlock()
+	ctx.importMap = m
+}
+
+// resolve the specifier name imported by the module baseName, ok is false if it's not mapped.
+func (m *ImportMap) resolve(baseName, name string) (res string, ok bool) {
+	if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "/") {
+		// the keys of relative specifiers are matched after resolving
+		name = NormalizeModuleName(baseName, name)
+	}
+
+	scopes := make([]string, 0, len(m.Scopes))
+	for scope := range m.Scopes {
+		if strings.HasPrefix(baseName, NormalizeModuleName("", scope)) {
+			scopes = append(scopes, scope)
+		}
+	}
+	// the most specific scope first
+	sort.Slice(scopes, func(i, j int) bool {
+		return len(scopes[i]) > len(scopes[j])
+	})
+	for _, scope := range scopes {
+		if res, ok = matchImports(m.Scopes[scope], name); ok {
+			return
+		}
+	}
+	return matchImports(m.Imports, name)
+}
+
+func matchImports(imports map[string]string, name string) (res string, ok bool) {
+	var prefix string
+	for key, target := range imports {
+		k := key
+		if strings.HasPrefix(key, ".") {
+			k = NormalizeModuleName("", key)
+			if strings.HasSuffix(key, "/") {
+				k += "/"
+			}
+		}
+		if k == name {
+			return NormalizeModuleName("", target), true
+		}
+		if strings.HasSuffix(k, "/") && strings.HasPrefix(name, k) && len(k) > len(prefix) {
+			prefix, res, ok = k, NormalizeModuleName("", target + name[len(k):]), true
+		}
+	}
+	return
+}
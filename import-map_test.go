@@ -0,0 +1,108 @@
+package quickjs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImportMap(t *testing.T) {
+	im, err := ParseImportMap([]byte(`{
+		"imports": {
+			"lodash": "./vendor/lodash/index.js",
+			"lodash/": "./vendor/lodash/",
+			"./old.js": "./new.js"
+		},
+		"scopes": {
+			"./legacy/": {"lodash/": "./vendor/lodash3/"}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseImportMap: %v", err)
+	}
+	modules := map[string]string{
+		"vendor/lodash/index.js": `export const v = "lodash";`,
+		"vendor/lodash/chunk.js": `export const v = "chunk4";`,
+		"vendor/lodash3/chunk.js": `export const v = "chunk3";`,
+		"new.js": `export const v = "new";`,
+		"legacy/a.js": `export { v } from "lodash/chunk.js";`,
+		"legacy/b.js": `export { v } from "lodash";`,
+	}
+	tests := []struct {
+		name   string
+		source string
+		want   interface{}
+	}{
+		{"exact", `export { v } from "lodash";`, "lodash"},
+		{"prefix", `export { v } from "lodash/chunk.js";`, "chunk4"},
+		{"relative key", `export { v } from "./old.js";`, "new"},
+		{"scope", `export { v } from "./legacy/a.js";`, "chunk3"},
+		{"scope fallback", `export { v } from "./legacy/b.js";`, "lodash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			ctx.SetModuleLoader(NewMapModuleLoader(modules))
+			ctx.SetImportMap(im)
+			m, err := ctx.EvalModule("main.js", tt.source)
+			if err != nil {
+				t.Fatalf("EvalModule: %v", err)
+			}
+			if res, _ := m.Get("v"); res != tt.want {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImportMapError(t *testing.T) {
+	if _, err := ParseImportMap([]byte(`{"imports": []}`)); err == nil {
+		t.Errorf("error expected")
+	}
+}
+
+func TestDataModules(t *testing.T) {
+	modules := map[string]string{
+		"cfg.json": `{"debug": true, "n": 1}`,
+		"data.txt": `{"not": "json"}`,
+		"page.html": "<p>hi</p>",
+		"logo.png": "\x89PNG",
+		"bad.json": `{`,
+	}
+	tests := []struct {
+		name   string
+		source string
+		want   interface{}
+	}{
+		{"json", `import cfg from "./cfg.json" with { type: "json" }; export const v = cfg.n;`, int64(1)},
+		{"json by extension", `import cfg from "./cfg.json"; export const v = cfg.debug;`, true},
+		{"json of other extension", `import d from "./data.txt" with { type: "json" }; export const v = d.not;`, "json"},
+		{"text", `import tpl from "./page.html" with { type: "text" }; export const v = tpl;`, "<p>hi</p>"},
+		{"bytes", `import logo from "./logo.png" with { type: "bytes" }; export const v = [logo instanceof Uint8Array, logo.length, logo[0]];`,
+			[]interface{}{true, int64(4), int64(0x89)}},
+		{"same module", `import a from "./cfg.json"; import b from "./cfg.json"; export const v = a === b;`, true},
+		{"dynamic", `const m = await import("./cfg.json"); export const v = m.default.n;`, int64(1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			ctx.SetModuleLoader(NewMapModuleLoader(modules))
+			m, err := ctx.EvalModule("main.js", tt.source)
+			if err != nil {
+				t.Fatalf("EvalModule: %v", err)
+			}
+			res, err := m.Get("v")
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+
+	ctx := newTestContext(t)
+	ctx.SetModuleLoader(NewMapModuleLoader(modules))
+	if _, err := ctx.EvalModule("main.js", `import "./bad.json";`); err == nil {
+		t.Errorf("importing bad JSON is expected to fail")
+	}
+}
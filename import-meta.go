@@ -0,0 +1,64 @@
+package quickjs
+
+/*
+#include "quickjs-libc.h"
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"unsafe"
+)
+
+// ImportMetaHook returns the properties added to `import.meta` of the module,
+// which can override `url` and `main`.
+type ImportMetaHook func(moduleName string) map[string]interface{}
+
+// SetImportMetaHook sets the hook called when a module is loaded, nil to remove it.
+func (ctx *JsContext) SetImportMetaHook(hook ImportMetaHook) {
+	ctx.lock()
+	defer ctx.unlock()
+	ctx.importMetaHook = hook
+}
+
+// add `import.meta.resolve()` and the properties given by the hook to the module,
+// after js_module_set_import_meta() is called. It returns -1 if an exception is thrown.
+func setImportMeta(ctx *C.JSContext, m *C.JSModuleDef) C.int {
+	jsCtx := getJsContext(ctx)
+	if jsCtx == nil {
+		return 0
+	}
+	atom := C.JS_GetModuleName(ctx, m)
+	cName := C.JS_AtomToCString(ctx, atom)
+	moduleName := C.GoString(cName)
+	C.JS_FreeCString(ctx, cName)
+	C.JS_FreeAtom(ctx, atom)
+
+	meta := C.JS_GetImportMeta(ctx, m)
+	if C.JS_IsException(meta) != 0 {
+		return -1
+	}
+	defer C.JS_FreeValue(ctx, meta)
+
+	setPropertyStr(ctx, meta, "resolve\x00", makeResolve(ctx, jsCtx, moduleName))
+	if jsCtx.importMetaHook == nil {
+		return 0
+	}
+	for k, v := range jsCtx.importMetaHook(moduleName) {
+		jsVal, err := makeJsValue(ctx, v)
+		if err != nil {
+			throwGoError(ctx, err)
+			return -1
+		}
+		cKey := C.CString(k)
+		C.JS_SetPropertyStr(ctx, meta, cKey, jsVal)
+		C.free(unsafe.Pointer(cKey))
+	}
+	return 0
+}
+
+// `import.meta.resolve(specifier)` returns the module name imported by moduleName.
+func makeResolve(ctx *C.JSContext, jsCtx *jsContext, moduleName string) C.JSValue {
+	return bindGoFunc(ctx, func(specifier string) (string, error) {
+		return jsCtx.normalizeModule(moduleName, specifier)
+	})
+}
@@ -0,0 +1,68 @@
+package quickjs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImportMeta(t *testing.T) {
+	mem := map[string]string{
+		"lib/dep.js": `export const meta = {url: import.meta.url, tenant: import.meta.tenant};`,
+		"lib/a.js": `export default "a";`,
+	}
+	hook := func(moduleName string) map[string]interface{} {
+		return map[string]interface{}{
+			"url": "https://acme.example/" + moduleName,
+			"tenant": "acme",
+		}
+	}
+	tests := []struct {
+		name   string
+		hook   ImportMetaHook
+		source string
+		want   interface{}
+	}{
+		{"resolve relative", nil, `export const res = import.meta.resolve("./a.js");`, "lib/a.js"},
+		{"resolve parent", nil, `export const res = import.meta.resolve("../lib/a.js");`, "lib/a.js"},
+		{"resolve missing", nil, `export let res; try { import.meta.resolve("./none.js"); } catch (e) { res = "thrown"; }`, "thrown"},
+		{"without hook", nil, `export const res = import.meta.tenant;`, nil},
+		{"hook", hook, `export const res = [import.meta.url, import.meta.tenant];`,
+			[]interface{}{"https://acme.example/lib/main.js", "acme"}},
+		{"hook of imported module", hook, `import { meta } from "./dep.js"; export const res = meta;`,
+			map[string]interface{}{"url": "https://acme.example/lib/dep.js", "tenant": "acme"}},
+		{"resolve kept with hook", hook, `export const res = import.meta.resolve("./a.js");`, "lib/a.js"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			ctx.SetModuleLoader(NewMapModuleLoader(mem))
+			ctx.SetImportMetaHook(tt.hook)
+			m, err := ctx.EvalModule("lib/main.js", tt.source)
+			if err != nil {
+				t.Fatalf("EvalModule: %v", err)
+			}
+			res, err := m.Get("res")
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportMetaHookRemoved(t *testing.T) {
+	ctx := newTestContext(t)
+	ctx.SetImportMetaHook(func(string) map[string]interface{} {
+		return map[string]interface{}{"tenant": "acme"}
+	})
+	ctx.SetImportMetaHook(nil)
+	m, err := ctx.EvalModule("main.js", `export const res = import.meta.tenant;`)
+	if err != nil {
+		t.Fatalf("EvalModule: %v", err)
+	}
+	if res, err := m.Get("res"); err != nil || res != nil {
+		t.Errorf("got %#v, %v, want nil", res, err)
+	}
+}
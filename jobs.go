@@ -0,0 +1,58 @@
+package quickjs
+
+// #include "quickjs.h"
+import "C"
+
+// SetAutoRunJobs sets whether the pending jobs, such as the callbacks of promises and
+// the continuations of async functions, are run after Eval, CallFunc and calling a
+// bound func return. It's on by default.
+func (ctx *JsContext) SetAutoRunJobs(on bool) {
+	ctx.lock()
+	defer ctx.unlock()
+	ctx.autoRunJobs = on
+}
+
+// RunPendingJobs runs the pending jobs until the job queue is empty, including those
+// settling the Promises of the async golang funcs returned. The exception thrown by
+// a job is returned as error.
+func (ctx *JsContext) RunPendingJobs() error {
+	ctx.lock()
+	defer ctx.unlock()
+	return ctx.runPendingJobs()
+}
+
+// HasPendingJobs reports whether there are jobs waiting to run. The async golang
+// funcs not returned yet are not counted.
+func (ctx *JsContext) HasPendingJobs() bool {
+	ctx.lock()
+	defer ctx.unlock()
+	return C.JS_IsJobPending(ctx.rt.rt) != 0 || ctx.tasks.hasReady()
+}
+
+func (ctx *jsContext) runPendingJobs() error {
+	for {
+		var c *C.JSContext
+		switch r := C.JS_ExecutePendingJob(ctx.rt.rt, &c); {
+		case r < 0:
+			return fromJsException(c)
+		case r == 0:
+			// the tasks posted by async golang funcs and timers make more jobs
+			ran, err := ctx.runTasks()
+			if err != nil {
+				return err
+			}
+			if !ran {
+				return nil
+			}
+		}
+	}
+}
+
+// run the pending jobs when a call from Go is done, if auto-running is on. Jobs are
+// not run in the nested calls of a golang func called by JS.
+func (ctx *JsContext) autoRunPendingJobs() error {
+	if ctx.inCallback || !ctx.autoRunJobs {
+		return nil
+	}
+	return ctx.runPendingJobs()
+}
@@ -0,0 +1,123 @@
+package quickjs
+
+import (
+	"testing"
+)
+
+const testJobsScript = `
+	var log = [];
+	function start() {
+		Promise.resolve().then(() => log.push("then"));
+		(async () => { await null; log.push("async"); })();
+		return "started";
+	}
+	function fail() {
+		queueMicrotask(() => { throw new Error("job failed"); });
+		return "started";
+	}
+`
+
+func TestAutoRunJobs(t *testing.T) {
+	tests := []struct {
+		name    string
+		autoRun bool
+		call    func(*JsContext) error
+		want    string // the log after the call
+	}{
+		{"eval", true, func(ctx *JsContext) error {
+			_, err := ctx.Eval(`start()`, nil)
+			return err
+		}, "then,async"},
+		{"call", true, func(ctx *JsContext) error {
+			_, err := ctx.CallFunc("start")
+			return err
+		}, "then,async"},
+		{"bound func", true, func(ctx *JsContext) error {
+			var start func() (string, error)
+			if err := ctx.BindFunc("start", &start); err != nil {
+				return err
+			}
+			_, err := start()
+			return err
+		}, "then,async"},
+		{"eval off", false, func(ctx *JsContext) error {
+			_, err := ctx.Eval(`start()`, nil)
+			return err
+		}, ""},
+		{"call off", false, func(ctx *JsContext) error {
+			_, err := ctx.CallFunc("start")
+			return err
+		}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			if _, err := ctx.Eval(testJobsScript, nil); err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			ctx.SetAutoRunJobs(tt.autoRun)
+			if err := tt.call(ctx); err != nil {
+				t.Fatalf("call: %v", err)
+			}
+			if got, _ := ctx.Eval(`log.join(",")`, nil); got != tt.want {
+				t.Errorf("got log %q, want %q", got, tt.want)
+			}
+			if pending := ctx.HasPendingJobs(); pending == tt.autoRun {
+				t.Errorf("HasPendingJobs: got %v", pending)
+			}
+
+			// the jobs left are run manually
+			if err := ctx.RunPendingJobs(); err != nil {
+				t.Fatalf("RunPendingJobs: %v", err)
+			}
+			if got, _ := ctx.Eval(`log.join(",")`, nil); got != "then,async" {
+				t.Errorf("got log %q after RunPendingJobs", got)
+			}
+			if ctx.HasPendingJobs() {
+				t.Errorf("jobs pending after RunPendingJobs")
+			}
+		})
+	}
+}
+
+func TestPendingJobErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(*JsContext) error
+	}{
+		{"eval", func(ctx *JsContext) error {
+			_, err := ctx.Eval(`fail()`, nil)
+			return err
+		}},
+		{"call", func(ctx *JsContext) error {
+			_, err := ctx.CallFunc("fail")
+			return err
+		}},
+		{"bound func", func(ctx *JsContext) error {
+			var fail func() (string, error)
+			if err := ctx.BindFunc("fail", &fail); err != nil {
+				t.Fatalf("BindFunc: %v", err)
+			}
+			_, err := fail()
+			return err
+		}},
+		{"manual", func(ctx *JsContext) error {
+			ctx.SetAutoRunJobs(false)
+			if _, err := ctx.CallFunc("fail"); err != nil {
+				t.Fatalf("CallFunc: %v", err)
+			}
+			return ctx.RunPendingJobs()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			if _, err := ctx.Eval(testJobsScript, nil); err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			if err := tt.call(ctx); err == nil {
+				t.Errorf("the error of the job expected")
+			}
+		})
+	}
+}
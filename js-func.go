@@ -46,16 +46,30 @@ func bindFunc(ctx *JsContext, funcName string, funcVarPtr interface{}) (err erro
 
 func wrapFunc(ctx *JsContext, funcName string, helper *elutils.EmbeddingFuncHelper) elutils.FnGoFunc {
 	return func(args []reflect.Value) (results []reflect.Value) {
-		ctx.mu.Lock()
-		defer ctx.mu.Unlock()
+		ctx.lock()
+		defer ctx.unlock()
 
 		// reload the function when calling go-function
 		jsFunc, _ := ctx.getVar(funcName)
 		defer C.JS_FreeValue(ctx.c, jsFunc)
-		return callJsFuncFromGo(ctx.c, jsFunc, helper, args)
+		results = callJsFuncFromGo(ctx.c, jsFunc, helper, args)
+		return ctx.runJobsAfterCall(helper, results)
 	}
 }
 
+// run the pending jobs after a bound func returns. If the call succeeded, the exception
+// thrown by a job is returned as the error of the call, if the func has an error result.
+func (ctx *JsContext) runJobsAfterCall(helper *elutils.EmbeddingFuncHelper, results []reflect.Value) []reflect.Value {
+	nOut, withLastErr := helper.NumOut()
+	if withLastErr && !results[nOut-1].IsNil() {
+		return results
+	}
+	if err := ctx.autoRunPendingJobs(); err != nil && withLastErr {
+		return helper.ToGolangResults(nil, false, err)
+	}
+	return results
+}
+
 // called by wrapFunc() and fromJsFunc::bindGoFunc()
 func callJsFuncFromGo(ctx *C.JSContext, jsFunc C.JSValue, helper *elutils.EmbeddingFuncHelper, args []reflect.Value)  (results []reflect.Value) {
 	argc := C.int(len(args))
@@ -82,6 +96,18 @@ func callJsFuncFromGo(ctx *C.JSContext, jsFunc C.JSValue, helper *elutils.Embedd
 	jsRes := C.JS_Call(ctx, jsFunc, C.toUndefined(), argc, jsArgs)
 	C.freeJsArgs(ctx, jsArgs, argc)
 
+	// a returned Promise is awaited if the golang func expects results
+	if nOut, _ := helper.NumOut(); nOut > 0 && isPromise(ctx, jsRes) {
+		if jsCtx := getJsContext(ctx); jsCtx != nil {
+			r, e := jsCtx.await(jsCtx.goCtx, jsRes)
+			C.JS_FreeValue(ctx, jsRes)
+			if e != nil {
+				return helper.ToGolangResults(nil, false, e)
+			}
+			jsRes = r
+		}
+	}
+
 	// convert result to golang
 	goVal, err := fromJsValue(ctx, jsRes)
 	// fmt.Printf("goVal: %v, err: %v\n", goVal, err)
@@ -0,0 +1,179 @@
+package quickjs
+
+/*
+#include "quickjs-libc.h"
+#include "go-proxy.h"
+#include <stdlib.h>
+*/
+import "C"
+import (
+	elutils "github.com/rosbit/go-embedding-utils"
+	"reflect"
+	"runtime"
+	"unsafe"
+	"fmt"
+)
+
+// Module is the handle of an ES module evaluated by EvalModule, through which
+// the exports of the module can be reached.
+type Module struct {
+	ctx  *JsContext
+	name string
+	ns   uint32 // id of the module namespace held by the context
+}
+
+// EvalModule evaluates source as an ES module named name, which is also used to
+// resolve the modules imported by it. The returned Module gives the exports.
+func (ctx *JsContext) EvalModule(name string, source string) (m *Module, err error) {
+	ctx.lock()
+	defer ctx.unlock()
+
+	c := ctx.c
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	cSource := C.CString(source)
+	defer C.free(unsafe.Pointer(cSource))
+
+	funcVal := C.JS_Eval(c, cSource, C.size_t(len(source)), cName, C.JS_EVAL_TYPE_MODULE | C.JS_EVAL_FLAG_COMPILE_ONLY)
+	if C.JS_IsException(funcVal) != 0 {
+		err = fromJsException(c)
+		return
+	}
+	def := C.moduleOf(funcVal)
+	C.js_module_set_import_meta(c, funcVal, 0, 0)
+	if setImportMeta(c, def) != 0 {
+		C.JS_FreeValue(c, funcVal)
+		err = fromJsException(c)
+		return
+	}
+	res, e := ctx.awaitModule(C.JS_EvalFunction(c, funcVal))
+	if e != nil {
+		err = e
+		return
+	}
+	C.JS_FreeValue(c, res)
+	if err = ctx.autoRunPendingJobs(); err != nil {
+		return
+	}
+
+	ns := C.JS_GetModuleNamespace(c, def)
+	if C.JS_IsException(ns) != 0 {
+		err = fromJsException(c)
+		return
+	}
+	m = &Module{ctx: ctx, name: name, ns: ctx.hold(ns)}
+	runtime.SetFinalizer(m, freeModule)
+	return
+}
+
+// the namespace is released when the context is locked next time, the finalizer must
+// not wait for the context used by others.
+func freeModule(m *Module) {
+	m.ctx.releaseLater(m.ns)
+}
+
+func (m *Module) namespace() C.JSValue {
+	return m.ctx.held[m.ns]
+}
+
+// Name returns the name of the module given to EvalModule.
+func (m *Module) Name() string {
+	return m.name
+}
+
+// Get returns the value of the export, nil if the module doesn't export it.
+func (m *Module) Get(exportName string) (res interface{}, err error) {
+	m.ctx.lock()
+	defer m.ctx.unlock()
+
+	c := m.ctx.c
+	v, e := getVar(c, m.namespace(), exportName)
+	if e != nil {
+		err = e
+		return
+	}
+	defer C.JS_FreeValue(c, v)
+	res, err = fromJsValue(c, v)
+	return
+}
+
+// Call calls the exported function with args.
+func (m *Module) Call(exportName string, args ...interface{}) (res interface{}, err error) {
+	m.ctx.lock()
+	defer m.ctx.unlock()
+
+	c := m.ctx.c
+	fn, e := m.getFunc(exportName)
+	if e != nil {
+		err = e
+		return
+	}
+	defer C.JS_FreeValue(c, fn)
+
+	r, e := callFunc(c, fn, args...)
+	if e != nil {
+		err = e
+		return
+	}
+	defer C.JS_FreeValue(c, r)
+	if C.JS_IsException(r) == 0 {
+		if err = m.ctx.autoRunPendingJobs(); err != nil {
+			return
+		}
+	}
+	res, err = fromJsValue(c, r)
+	return
+}
+
+// BindFunc binds a var of golang func with the exported function, just like
+// JsContext.BindFunc does with a global function.
+func (m *Module) BindFunc(exportName string, funcVarPtr interface{}) (err error) {
+	if funcVarPtr == nil {
+		err = fmt.Errorf("funcVarPtr must be a non-nil poiter of func")
+		return
+	}
+	t := reflect.TypeOf(funcVarPtr)
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Func {
+		err = fmt.Errorf("funcVarPtr expected to be a pointer of func")
+		return
+	}
+
+	m.ctx.lock()
+	fn, e := m.getFunc(exportName)
+	if e != nil {
+		m.ctx.unlock()
+		err = e
+		return
+	}
+	C.JS_FreeValue(m.ctx.c, fn)
+	m.ctx.unlock()
+
+	helper, e := elutils.NewEmbeddingFuncHelper(funcVarPtr)
+	if e != nil {
+		err = e
+		return
+	}
+	helper.BindEmbeddingFunc(func(args []reflect.Value) (results []reflect.Value) {
+		m.ctx.lock()
+		defer m.ctx.unlock()
+
+		// exports are live bindings, so get the function when calling it
+		jsFunc, _ := getVar(m.ctx.c, m.namespace(), exportName)
+		defer C.JS_FreeValue(m.ctx.c, jsFunc)
+		results = callJsFuncFromGo(m.ctx.c, jsFunc, helper, args)
+		return m.ctx.runJobsAfterCall(helper, results)
+	})
+	return
+}
+
+func (m *Module) getFunc(exportName string) (fn C.JSValue, err error) {
+	c := m.ctx.c
+	if fn, err = getVar(c, m.namespace(), exportName); err != nil {
+		return
+	}
+	if C.JS_IsFunction(c, fn) == 0 {
+		C.JS_FreeValue(c, fn)
+		err = fmt.Errorf("export %s of module %s is not with type function", exportName, m.name)
+	}
+	return
+}
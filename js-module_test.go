@@ -0,0 +1,168 @@
+package quickjs
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testModuleSource = `
+	export let count = 0;
+	export function inc(n = 1) {
+		count += n;
+		return count;
+	}
+	export const config = {name: "m", tags: ["a"]};
+	export default "default value";
+	export const ready = await Promise.resolve("ready");
+`
+
+func TestModuleGet(t *testing.T) {
+	tests := []struct {
+		export string
+		want   interface{}
+	}{
+		{"config", map[string]interface{}{"name": "m", "tags": []interface{}{"a"}}},
+		{"default", "default value"},
+		{"ready", "ready"},
+		{"count", int64(0)},
+		{"missing", nil},
+	}
+	ctx := newTestContext(t)
+	m, err := ctx.EvalModule("m.js", testModuleSource)
+	if err != nil {
+		t.Fatalf("EvalModule: %v", err)
+	}
+	if m.Name() != "m.js" {
+		t.Errorf("got name %q", m.Name())
+	}
+	for _, tt := range tests {
+		t.Run(tt.export, func(t *testing.T) {
+			res, err := m.Get(tt.export)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestModuleCall(t *testing.T) {
+	ctx := newTestContext(t)
+	m, err := ctx.EvalModule("m.js", testModuleSource)
+	if err != nil {
+		t.Fatalf("EvalModule: %v", err)
+	}
+	if res, err := m.Call("inc", 2); err != nil || res != int64(2) {
+		t.Errorf("Call: got %v, %v", res, err)
+	}
+	var inc func(int) int
+	if err = m.BindFunc("inc", &inc); err != nil {
+		t.Fatalf("BindFunc: %v", err)
+	}
+	if n := inc(3); n != 5 {
+		t.Errorf("got %d", n)
+	}
+	// exports are live bindings
+	if res, _ := m.Get("count"); res != int64(5) {
+		t.Errorf("got count %v", res)
+	}
+
+	if _, err = m.Call("config"); err == nil {
+		t.Errorf("calling a non-func export is expected to fail")
+	}
+	if _, err = m.Call("missing"); err == nil {
+		t.Errorf("calling a missing export is expected to fail")
+	}
+	var f func()
+	if err = m.BindFunc("config", &f); err == nil {
+		t.Errorf("binding a non-func export is expected to fail")
+	}
+}
+
+func TestEvalModuleErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"syntax", `export const = 1;`, "SyntaxError"},
+		{"exception", `throw new Error("init failed");`, "init failed"},
+		{"rejected await", `await Promise.reject(new Error("rejected"));`, "rejected"},
+		{"missing import", `import { x } from "./missing-module-for-test.js";`, "missing-module-for-test.js"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			_, err := ctx.EvalModule("m.js", tt.source)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalModuleRunsPendingJobs(t *testing.T) {
+	ctx := newTestContext(t)
+	m, err := ctx.EvalModule("m.js", `
+		export let state = "evaluated";
+		Promise.resolve().then(() => { state = "job run"; });
+	`)
+	if err != nil {
+		t.Fatalf("EvalModule: %v", err)
+	}
+	if res, _ := m.Get("state"); res != "job run" {
+		t.Errorf("got state %v, want the pending job run", res)
+	}
+}
+
+// a Module collected while the context is busy must not block the finalizers.
+func TestModuleFreedWhileContextBusy(t *testing.T) {
+	ctx := newTestContext(t)
+	held := len(ctx.held)
+	func() {
+		if _, err := ctx.EvalModule("m.js", `export const x = 1;`); err != nil {
+			t.Fatalf("EvalModule: %v", err)
+		}
+	}()
+
+	entered, unblock, done := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	block := func() {
+		close(entered)
+		<-unblock
+	}
+	go func() {
+		defer close(done)
+		ctx.Eval(`block()`, map[string]interface{}{"block": block})
+	}()
+	<-entered
+
+	finalized := make(chan struct{})
+	sentinel := new(int)
+	runtime.SetFinalizer(sentinel, func(*int) { close(finalized) })
+	sentinel = nil
+	for i := 0; i < 3; i++ {
+		runtime.GC()
+	}
+	select {
+	case <-finalized:
+		close(unblock)
+		<-done
+	case <-time.After(5 * time.Second):
+		close(unblock)
+		<-done
+		t.Fatal("the finalizers are blocked by the busy context")
+	}
+
+	// the namespace is released by the next call
+	if _, err := ctx.Eval(`0`, nil); err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if len(ctx.held) != held {
+		t.Errorf("got %d values held, want %d", len(ctx.held), held)
+	}
+}
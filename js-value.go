@@ -0,0 +1,37 @@
+package quickjs
+
+// #include "go-proxy.h"
+import "C"
+
+// JsValue is a raw handle of a JS value, it is only valid during the call
+// of the golang func it is passed to.
+type JsValue struct {
+	ctx *C.JSContext
+	v   C.JSValue
+}
+
+// Value converts the JS value to golang value.
+func (v JsValue) Value() (interface{}, error) {
+	return fromJsValue(v.ctx, v.v)
+}
+
+func (v JsValue) IsUndefined() bool {
+	return C.JS_IsUndefined(v.v) != 0
+}
+
+func (v JsValue) IsNull() bool {
+	return C.JS_IsNull(v.v) != 0
+}
+
+func (v JsValue) IsFunction() bool {
+	return C.JS_IsFunction(v.ctx, v.v) != 0
+}
+
+func (v JsValue) IsObject() bool {
+	return C.JS_IsObject(v.v) != 0
+}
+
+// String converts the JS value to string as JS `String(v)`.
+func (v JsValue) String() string {
+	return toGoString(v.ctx, v.v)
+}
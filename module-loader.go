@@ -0,0 +1,276 @@
+package quickjs
+
+/*
+#include "quickjs-libc.h"
+#include <stdlib.h>
+
+
+static JSModuleDef *compileModule(JSContext *ctx, const char *source, size_t len, const char *module_name) {
+	JSValue func_val = JS_Eval(ctx, source, len, module_name, JS_EVAL_TYPE_MODULE | JS_EVAL_FLAG_COMPILE_ONLY);
+	if (JS_IsException(func_val)) {
+		return NULL;
+	}
+	js_module_set_import_meta(ctx, func_val, 0, 0);
+	// the module is already referenced, so we must free it
+	JS_FreeValue(ctx, func_val);
+	return JS_VALUE_GET_PTR(func_val);
+}
+*/
+import "C"
+import (
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"errors"
+	"unsafe"
+	"fmt"
+)
+
+// ErrModuleNotFound is returned by a ModuleLoader which doesn't know the module,
+// so the next loader in a chain is tried. It can be wrapped, which is checked by errors.Is.
+var ErrModuleNotFound = errors.New("module not found")
+
+// ModuleLoader resolves and loads the ES modules imported by scripts.
+type ModuleLoader interface {
+	// Normalize returns the full name of moduleName imported by the module baseName,
+	// which is the key of the module cache and is passed to Load.
+	Normalize(baseName, moduleName string) (string, error)
+	// Load returns the source of the module with the full name.
+	Load(moduleName string) (source string, err error)
+}
+
+// SetModuleLoader makes the modules imported by scripts loaded by loader instead
+// of the files relative to the process. The modules registered by RegisterModule,
+// and the C modules "std" and "os", are still preferred. A nil loader restores the default one.
+func (ctx *JsContext) SetModuleLoader(loader ModuleLoader) {
+	ctx.lock()
+	defer ctx.unlock()
+
+	ctx.loader = loader
+}
+
+// NormalizeModuleName resolves a relative moduleName like "./a.js" or "../a.js" against
+// the directory of baseName, other names are returned as they are.
+func NormalizeModuleName(baseName, moduleName string) string {
+	if !strings.HasPrefix(moduleName, ".") {
+		return moduleName
+	}
+	return path.Join(path.Dir(baseName), moduleName)
+}
+
+type fsModuleLoader struct {
+	fsys fs.FS
+}
+
+// NewFSModuleLoader creates a ModuleLoader reading modules from fsys, such as an
+// embed.FS or os.DirFS(dir). The extension ".js" can be omitted when importing.
+func NewFSModuleLoader(fsys fs.FS) ModuleLoader {
+	return &fsModuleLoader{fsys: fsys}
+}
+
+func (l *fsModuleLoader) Normalize(baseName, moduleName string) (string, error) {
+	name := strings.TrimPrefix(NormalizeModuleName(baseName, moduleName), "/")
+	for _, n := range []string{name, name + ".js"} {
+		if !fs.ValidPath(n) {
+			continue
+		}
+		if fi, err := fs.Stat(l.fsys, n); err == nil && !fi.IsDir() {
+			return n, nil
+		}
+	}
+	return "", ErrModuleNotFound
+}
+
+func (l *fsModuleLoader) Load(moduleName string) (source string, err error) {
+	b, e := fs.ReadFile(l.fsys, moduleName)
+	if e != nil {
+		if errors.Is(e, fs.ErrNotExist) {
+			err = ErrModuleNotFound
+		} else {
+			err = e
+		}
+		return
+	}
+	source = string(b)
+	return
+}
+
+type mapModuleLoader map[string]string
+
+// NewMapModuleLoader creates a ModuleLoader with the sources of modules in memory,
+// keyed by module names like "lib/util.js".
+func NewMapModuleLoader(modules map[string]string) ModuleLoader {
+	return mapModuleLoader(modules)
+}
+
+func (l mapModuleLoader) Normalize(baseName, moduleName string) (string, error) {
+	name := NormalizeModuleName(baseName, moduleName)
+	for _, n := range []string{name, name + ".js"} {
+		if _, ok := l[n]; ok {
+			return n, nil
+		}
+	}
+	return "", ErrModuleNotFound
+}
+
+func (l mapModuleLoader) Load(moduleName string) (string, error) {
+	if source, ok := l[moduleName]; ok {
+		return source, nil
+	}
+	return "", ErrModuleNotFound
+}
+
+type chainModuleLoader []ModuleLoader
+
+// NewChainModuleLoader creates a ModuleLoader trying loaders in order, the first
+// one which doesn't return ErrModuleNotFound is used.
+func NewChainModuleLoader(loaders ...ModuleLoader) ModuleLoader {
+	return chainModuleLoader(loaders)
+}
+
+func (l chainModuleLoader) Normalize(baseName, moduleName string) (string, error) {
+	for _, loader := range l {
+		name, err := loader.Normalize(baseName, moduleName)
+		if !errors.Is(err, ErrModuleNotFound) {
+			return name, err
+		}
+	}
+	return "", ErrModuleNotFound
+}
+
+func (l chainModuleLoader) Load(moduleName string) (string, error) {
+	for _, loader := range l {
+		source, err := loader.Load(moduleName)
+		if !errors.Is(err, ErrModuleNotFound) {
+			return source, err
+		}
+	}
+	return "", ErrModuleNotFound
+}
+
+//export goModuleNormalize
+func goModuleNormalize(ctx *C.JSContext, module_base_name *C.char, module_name *C.char, opaque unsafe.Pointer) (res *C.char) {
+	defer func() {
+		if r := recover(); r != nil {
+			throwGoPanic(ctx, r)
+			res = nil
+		}
+	}()
+
+	jsCtx := getJsContext(ctx)
+	if jsCtx == nil {
+		return C.js_strdup(ctx, module_name)
+	}
+	fullName, err := jsCtx.normalizeModule(C.GoString(module_base_name), C.GoString(module_name))
+	if err != nil {
+		throwGoError(ctx, err)
+		return nil
+	}
+	cName := C.CString(fullName)
+	defer C.free(unsafe.Pointer(cName))
+	return C.js_strdup(ctx, cName)
+}
+
+// resolve moduleName imported by baseName with the import map and the module loader.
+// the type given by import attributes is kept as "?type=xxx".
+func (ctx *jsContext) normalizeModule(baseName, moduleName string) (fullName string, err error) {
+	name, _ := splitModuleQuery(moduleName)
+	baseName, _ = splitModuleQuery(baseName)
+	if _, ok := ctx.modules[name]; ok {
+		return moduleName, nil
+	}
+	if ctx.loader != nil && ctx.hasCModule(name) {
+		// the C modules registered by quickjs-libc, such as "std" and "os"
+		return moduleName, nil
+	}
+	if ctx.importMap != nil {
+		if mapped, ok := ctx.importMap.resolve(baseName, name); ok {
+			name, baseName = mapped, ""
+		}
+	}
+	if ctx.loader != nil {
+		if name, err = ctx.loader.Normalize(baseName, name); err != nil {
+			if errors.Is(err, ErrModuleNotFound) {
+				err = fmt.Errorf("could not load module '%s'", moduleName)
+			}
+			return
+		}
+	} else {
+		name = NormalizeModuleName(baseName, name)
+	}
+	// only the types of data modules are kept in the query string
+	switch moduleType := getModuleType(moduleName); {
+	case len(moduleType) == 0, moduleType == "json" && strings.HasSuffix(name, ".json"):
+		fullName = name
+	default:
+		fullName = name + "?type=" + moduleType
+	}
+	return
+}
+
+func (ctx *jsContext) hasCModule(name string) bool {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	return C.JS_HasCModule(ctx.c, cName) != 0
+}
+
+// split "name?type=json" to "name" and "?type=json"
+func splitModuleQuery(moduleName string) (name string, query string) {
+	if i := strings.IndexByte(moduleName, '?'); i >= 0 {
+		return moduleName[:i], moduleName[i:]
+	}
+	return moduleName, ""
+}
+
+// the type of a module given by import attributes, "json" is the default one of
+// the names with extension ".json". An empty type is for JS source.
+func getModuleType(moduleName string) string {
+	name, query := splitModuleQuery(moduleName)
+	if len(query) > 0 {
+		if q, err := url.ParseQuery(query[1:]); err == nil {
+			if t := q.Get("type"); len(t) > 0 && t != "js" && t != "javascript" {
+				return t
+			}
+		}
+	}
+	if strings.HasSuffix(name, ".json") {
+		return "json"
+	}
+	return ""
+}
+
+// get the content of a module, from the loader if it's set, or from the file.
+func loadModuleSource(loader ModuleLoader, moduleName string) (source string, err error) {
+	if loader == nil {
+		b, e := os.ReadFile(moduleName)
+		if e != nil {
+			err = fmt.Errorf("could not load module filename '%s'", moduleName)
+			return
+		}
+		source = string(b)
+		return
+	}
+	if source, err = loader.Load(moduleName); errors.Is(err, ErrModuleNotFound) {
+		err = fmt.Errorf("could not load module '%s'", moduleName)
+	}
+	return
+}
+
+// load the module from the loader set by SetModuleLoader.
+func loadModule(ctx *C.JSContext, loader ModuleLoader, module_name *C.char) *C.JSModuleDef {
+	source, err := loadModuleSource(loader, C.GoString(module_name))
+	if err != nil {
+		throwGoError(ctx, err)
+		return nil
+	}
+
+	cSource := C.CString(source)
+	defer C.free(unsafe.Pointer(cSource))
+	m := C.compileModule(ctx, cSource, C.size_t(len(source)), module_name)
+	if m == nil || setImportMeta(ctx, m) != 0 {
+		return nil
+	}
+	return m
+}
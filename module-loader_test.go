@@ -0,0 +1,115 @@
+package quickjs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNormalizeModuleName(t *testing.T) {
+	tests := []struct {
+		base, name string
+		want       string
+	}{
+		{"main.js", "./a.js", "a.js"},
+		{"lib/main.js", "./a.js", "lib/a.js"},
+		{"lib/sub/main.js", "../a.js", "lib/a.js"},
+		{"lib/main.js", "pkg", "pkg"},
+		{"lib/main.js", "/abs.js", "/abs.js"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeModuleName(tt.base, tt.name); got != tt.want {
+			t.Errorf("NormalizeModuleName(%q, %q) = %q, want %q", tt.base, tt.name, got, tt.want)
+		}
+	}
+}
+
+// a loader knowing nothing, which returns the wrapped ErrModuleNotFound.
+type emptyModuleLoader struct{}
+
+func (emptyModuleLoader) Normalize(baseName, moduleName string) (string, error) {
+	return "", fmt.Errorf("%s: %w", moduleName, ErrModuleNotFound)
+}
+
+func (emptyModuleLoader) Load(moduleName string) (string, error) {
+	return "", fmt.Errorf("%s: %w", moduleName, ErrModuleNotFound)
+}
+
+func TestModuleLoaders(t *testing.T) {
+	fsys := fstest.MapFS{
+		"lib/util.js": {Data: []byte(`export const util = "fs";`)},
+		"lib/dep.js": {Data: []byte(`import { util } from "./util.js"; export const dep = util + "-dep";`)},
+		"shadowed.js": {Data: []byte(`export const from = "fs";`)},
+	}
+	mem := map[string]string{
+		"mem/a.js": `export const a = "mem";`,
+		"shadowed.js": `export const from = "mem";`,
+	}
+	tests := []struct {
+		name   string
+		loader ModuleLoader
+		source string
+		want   interface{}
+	}{
+		{"fs", NewFSModuleLoader(fsys), `import { util } from "./lib/util.js"; export const res = util;`, "fs"},
+		{"fs without extension", NewFSModuleLoader(fsys), `import { util } from "./lib/util"; export const res = util;`, "fs"},
+		{"fs relative", NewFSModuleLoader(fsys), `import { dep } from "lib/dep.js"; export const res = dep;`, "fs-dep"},
+		{"map", NewMapModuleLoader(mem), `import { a } from "./mem/a"; export const res = a;`, "mem"},
+		{"chain order", NewChainModuleLoader(NewMapModuleLoader(mem), NewFSModuleLoader(fsys)),
+			`import { from } from "./shadowed.js"; export const res = from;`, "mem"},
+		{"chain fallback", NewChainModuleLoader(emptyModuleLoader{}, NewMapModuleLoader(mem), NewFSModuleLoader(fsys)),
+			`import { util } from "./lib/util.js"; export const res = util;`, "fs"},
+		{"registered module preferred", NewMapModuleLoader(map[string]string{"native": `export const n = "loader";`}),
+			`import { n } from "native"; export const res = n;`, "go"},
+		{"std with fs", NewFSModuleLoader(fsys), `import * as std from "std"; export const res = typeof std.printf;`, "function"},
+		{"os with map", NewMapModuleLoader(mem), `import { sleep } from "os"; export const res = typeof sleep;`, "function"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			ctx.SetModuleLoader(tt.loader)
+			if err := ctx.RegisterModule("native", map[string]interface{}{"n": "go"}); err != nil {
+				t.Fatalf("RegisterModule: %v", err)
+			}
+			m, err := ctx.EvalModule("main.js", tt.source)
+			if err != nil {
+				t.Fatalf("EvalModule: %v", err)
+			}
+			res, err := m.Get("res")
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestModuleNotFound(t *testing.T) {
+	tests := []struct {
+		name   string
+		loader ModuleLoader
+	}{
+		{"fs", NewFSModuleLoader(fstest.MapFS{})},
+		{"map", NewMapModuleLoader(nil)},
+		{"chain", NewChainModuleLoader(emptyModuleLoader{}, NewMapModuleLoader(nil))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.loader.Normalize("main.js", "./missing.js"); !errors.Is(err, ErrModuleNotFound) {
+				t.Errorf("Normalize: got %v, want ErrModuleNotFound", err)
+			}
+			if _, err := tt.loader.Load("missing.js"); !errors.Is(err, ErrModuleNotFound) {
+				t.Errorf("Load: got %v, want ErrModuleNotFound", err)
+			}
+			ctx := newTestContext(t)
+			ctx.SetModuleLoader(tt.loader)
+			if _, err := ctx.EvalModule("main.js", `import "./missing.js";`); err == nil {
+				t.Errorf("EvalModule: error expected")
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package quickjs
+
+/*
+#include "quickjs-libc.h"
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"unsafe"
+)
+
+const domExceptionPrelude = `(function() {
+	if (typeof globalThis.DOMException === "function") {
+		return;
+	}
+	class DOMException extends Error {
+		#name;
+		constructor(message = "", name = "Error") {
+			super(message);
+			this.#name = String(name);
+		}
+		get name() {
+			return this.#name;
+		}
+	}
+	Object.defineProperty(globalThis, "DOMException", {value: DOMException, writable: true, configurable: true});
+})`
+
+// evaluate the source of a JS function expression and call it with args. It is used
+// to build the objects implemented in JS with some golang helpers, which are passed
+// as args instead of being put in the global object.
+// the result must be freed by the caller.
+func evalPrelude(ctx *C.JSContext, name string, source string, args ...interface{}) (res C.JSValue, err error) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	cSource := C.CString(source)
+	defer C.free(unsafe.Pointer(cSource))
+
+	fn := C.JS_Eval(ctx, cSource, C.size_t(len(source)), cName, C.JS_EVAL_TYPE_GLOBAL)
+	if C.JS_IsException(fn) != 0 {
+		err = fromJsException(ctx)
+		return
+	}
+	defer C.JS_FreeValue(ctx, fn)
+
+	res, err = callFunc(ctx, fn, args...)
+	if err == nil && C.JS_IsException(res) != 0 {
+		err = fromJsException(ctx)
+	}
+	return
+}
+
+// get the result of a prelude held by the context, which is evaluated when it's used first,
+// id is the field keeping the id of the held value. The result must not be freed.
+func (ctx *jsContext) heldPrelude(id *uint32, name string, source string) (res C.JSValue, err error) {
+	if *id == 0 {
+		v, e := evalPrelude(ctx.c, name, source)
+		if e != nil {
+			err = e
+			return
+		}
+		*id = ctx.hold(v)
+	}
+	res = ctx.held[*id]
+	return
+}
+
+// set a global var of the context, the ownership of val is taken
+func setGlobal(ctx *C.JSContext, name string, val C.JSValue) {
+	global := C.JS_GetGlobalObject(ctx)
+	defer C.JS_FreeValue(ctx, global)
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	C.JS_SetPropertyStr(ctx, global, cName, val)
+}
+
+// define the `DOMException` thrown by the web APIs in the global object, if it's absent.
+func defineDOMException(ctx *C.JSContext) error {
+	res, err := evalPrelude(ctx, "<DOMException>", domExceptionPrelude)
+	if err != nil {
+		return err
+	}
+	C.JS_FreeValue(ctx, res)
+	return nil
+}
@@ -27760,6 +27760,21 @@ static JSModuleDef *js_find_loaded_module(JSContext *ctx, JSAtom name)
     return NULL;
 }
 
+int JS_HasCModule(JSContext *ctx, const char *module_name)
+{
+    JSModuleDef *m;
+    JSAtom name;
+
+    name = JS_NewAtom(ctx, module_name);
+    if (name == JS_ATOM_NULL) {
+        JS_FreeValue(ctx, JS_GetException(ctx));
+        return FALSE;
+    }
+    m = js_find_loaded_module(ctx, name);
+    JS_FreeAtom(ctx, name);
+    return m != NULL && m->init_func != NULL;
+}
+
 /* return NULL in case of exception (e.g. module could not be loaded) */
 static JSModuleDef *js_host_resolve_imported_module(JSContext *ctx,
                                                     const char *base_cname,
@@ -29288,6 +29303,83 @@ static JSValue js_evaluate_module(JSContext *ctx, JSModuleDef *m)
     return JS_DupValue(ctx, m->promise);
 }
 
+/* the import attributes "with { type: 'json' }" following a module
+   specifier are appended to it as a query string "?type=json", which
+   is handled by the module normalizer and loader */
+static __exception int js_parse_with_clause(JSParseState *s, JSAtom *pmodule_name)
+{
+    JSContext *ctx = s->ctx;
+    StringBuffer b_s, *b = &b_s;
+    const char *str;
+    int sep;
+    JSAtom module_name;
+    JSValue str_val;
+
+    if (s->token.val != TOK_WITH)
+        return 0;
+    if (next_token(s))
+        return -1;
+    if (js_parse_expect(s, '{'))
+        return -1;
+
+    str = JS_AtomToCString(ctx, *pmodule_name);
+    if (!str)
+        return -1;
+    sep = strchr(str, '?') ? '&' : '?';
+    JS_FreeCString(ctx, str);
+    string_buffer_init(ctx, b, 0);
+    if (string_buffer_concat_value_free(b, JS_AtomToString(ctx, *pmodule_name)))
+        goto fail;
+    while (s->token.val != '}') {
+        if (s->token.val == TOK_STRING) {
+            if (string_buffer_putc8(b, sep) ||
+                string_buffer_concat_value(b, s->token.u.str.str))
+                goto fail;
+        } else if (token_is_ident(s->token.val)) {
+            if (string_buffer_putc8(b, sep) ||
+                string_buffer_concat_value_free(b, JS_AtomToString(ctx, s->token.u.ident.atom)))
+                goto fail;
+        } else {
+            js_parse_error(s, "identifier or string expected");
+            goto fail;
+        }
+        sep = '&';
+        if (next_token(s))
+            goto fail;
+        if (js_parse_expect(s, ':'))
+            goto fail;
+        if (s->token.val != TOK_STRING) {
+            js_parse_error(s, "string expected");
+            goto fail;
+        }
+        if (string_buffer_putc8(b, '=') ||
+            string_buffer_concat_value(b, s->token.u.str.str))
+            goto fail;
+        if (next_token(s))
+            goto fail;
+        if (s->token.val != ',')
+            break;
+        if (next_token(s))
+            goto fail;
+    }
+    if (js_parse_expect(s, '}'))
+        goto fail;
+
+    str_val = string_buffer_end(b);
+    if (JS_IsException(str_val))
+        return -1;
+    module_name = JS_ValueToAtom(ctx, str_val);
+    JS_FreeValue(ctx, str_val);
+    if (module_name == JS_ATOM_NULL)
+        return -1;
+    JS_FreeAtom(ctx, *pmodule_name);
+    *pmodule_name = module_name;
+    return 0;
+ fail:
+    string_buffer_free(b);
+    return -1;
+}
+
 static __exception JSAtom js_parse_from_clause(JSParseState *s)
 {
     JSAtom module_name;
@@ -29304,7 +29396,7 @@ static __exception JSAtom js_parse_from_clause(JSParseState *s)
     module_name = JS_ValueToAtom(s->ctx, s->token.u.str.str);
     if (module_name == JS_ATOM_NULL)
         return JS_ATOM_NULL;
-    if (next_token(s)) {
+    if (next_token(s) || js_parse_with_clause(s, &module_name)) {
         JS_FreeAtom(s->ctx, module_name);
         return JS_ATOM_NULL;
     }
@@ -29529,7 +29621,7 @@ static __exception int js_parse_import(JSParseState *s)
         module_name = JS_ValueToAtom(ctx, s->token.u.str.str);
         if (module_name == JS_ATOM_NULL)
             return -1;
-        if (next_token(s)) {
+        if (next_token(s) || js_parse_with_clause(s, &module_name)) {
             JS_FreeAtom(ctx, module_name);
             return -1;
         }
@@ -51889,6 +51981,44 @@ JSValue JS_GetTypedArrayBuffer(JSContext *ctx, JSValueConst obj,
     return JS_DupValue(ctx, JS_MKPTR(JS_TAG_OBJECT, ta->buffer));
 }
 
+/* get the bytes of an ArrayBuffer, a typed array or a DataView. FALSE is
+   returned without exception if obj is none of them or detached. *pdata
+   can be NULL if the buffer is empty */
+int JS_GetBufferBytes(JSContext *ctx, uint8_t **pdata, size_t *psize, JSValueConst obj)
+{
+    JSObject *p;
+    JSArrayBuffer *abuf;
+    JSTypedArray *ta;
+
+    *pdata = NULL;
+    *psize = 0;
+    if (JS_VALUE_GET_TAG(obj) != JS_TAG_OBJECT)
+        return FALSE;
+    p = JS_VALUE_GET_OBJ(obj);
+    switch (p->class_id) {
+    case JS_CLASS_ARRAY_BUFFER:
+    case JS_CLASS_SHARED_ARRAY_BUFFER:
+        abuf = p->u.array_buffer;
+        if (abuf->detached)
+            return FALSE;
+        *pdata = abuf->data;
+        *psize = abuf->byte_length;
+        return TRUE;
+    default:
+        if (p->class_id < JS_CLASS_UINT8C_ARRAY ||
+            p->class_id > JS_CLASS_DATAVIEW)
+            return FALSE;
+        ta = p->u.typed_array;
+        abuf = ta->buffer->u.array_buffer;
+        if (abuf->detached)
+            return FALSE;
+        if (abuf->data != NULL)
+            *pdata = abuf->data + ta->offset;
+        *psize = ta->length;
+        return TRUE;
+    }
+}
+
 static JSValue js_typed_array_get_toStringTag(JSContext *ctx,
                                               JSValueConst this_val)
 {
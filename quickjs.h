@@ -882,6 +882,7 @@ JSValue JS_GetTypedArrayBuffer(JSContext *ctx, JSValueConst obj,
                                size_t *pbyte_offset,
                                size_t *pbyte_length,
                                size_t *pbytes_per_element);
+int JS_GetBufferBytes(JSContext *ctx, uint8_t **pdata, size_t *psize, JSValueConst obj);
 typedef struct {
     void *(*sab_alloc)(void *opaque, size_t size);
     void (*sab_free)(void *opaque, void *ptr);
@@ -940,6 +941,8 @@ void JS_SetModuleLoaderFunc(JSRuntime *rt,
 JSValue JS_GetImportMeta(JSContext *ctx, JSModuleDef *m);
 JSAtom JS_GetModuleName(JSContext *ctx, JSModuleDef *m);
 JSValue JS_GetModuleNamespace(JSContext *ctx, JSModuleDef *m);
+/* return TRUE if a C module named module_name is registered, such as "std" */
+int JS_HasCModule(JSContext *ctx, const char *module_name);
 
 /* JS Job support */
 
@@ -0,0 +1,16 @@
+package quickjs
+
+import (
+	"testing"
+)
+
+// a context closed when the test is done.
+func newTestContext(t *testing.T) *JsContext {
+	t.Helper()
+	ctx, err := NewContext()
+	if err != nil {
+		t.Fatalf("failed to create context: %v", err)
+	}
+	t.Cleanup(ctx.Close)
+	return ctx
+}
@@ -0,0 +1,211 @@
+package quickjs
+
+/*
+#include "go-proxy.h"
+#include <stdlib.h>
+
+extern JSValue goRequireCompile(JSContext *ctx, JSValueConst this_val, int argc, JSValueConst *argv);
+
+static JSValue newRequireCompile(JSContext *ctx) {
+	return JS_NewCFunction(ctx, (JSCFunction*)goRequireCompile, "compile", 1);
+}
+*/
+import "C"
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"unsafe"
+	"fmt"
+)
+
+const requirePrelude = `(function(resolve, compile, builtin) {
+	const cache = Object.create(null);
+	function makeRequire(base) {
+		function require(name) {
+			if (typeof name !== "string") {
+				throw new TypeError("module name must be a string");
+			}
+			let module = cache[name];
+			if (module) {
+				return module.exports;
+			}
+			const [exports, isBuiltin] = builtin(name);
+			if (isBuiltin) {
+				module = cache[name] = {id: name, filename: name, exports: exports, loaded: true};
+				return exports;
+			}
+
+			const filename = resolve(base, name);
+			module = cache[filename];
+			if (module) {
+				return module.exports;
+			}
+			module = cache[filename] = {id: filename, filename: filename, exports: {}, loaded: false};
+			try {
+				const fn = compile(filename);
+				if (typeof fn === "function") {
+					const i = filename.lastIndexOf("/");
+					const dirname = i < 0 ? "." : filename.substring(0, i);
+					fn.call(module.exports, module.exports, makeRequire(filename), module, filename, dirname);
+				} else {
+					module.exports = fn;
+				}
+			} catch (e) {
+				delete cache[filename];
+				throw e;
+			}
+			module.loaded = true;
+			return module.exports;
+		}
+		require.resolve = function(name) {
+			const [, isBuiltin] = builtin(name);
+			return isBuiltin ? name : resolve(base, name);
+		};
+		require.cache = cache;
+		return require;
+	}
+	return makeRequire("");
+})`
+
+// EnableRequire adds the CommonJS `require()` to the global object. The modules are
+// resolved in fsys with the lookup of Node.js: the relative names against the directory
+// of the requiring module, and the other names in the `node_modules` directories of
+// it and its ancestors. The extensions ".js" and ".json", `main` of package.json and
+// the index files of directories are tried. The modules registered by RegisterModule
+// are builtin, which are required with their names. If fsys is nil, the current
+// directory is used.
+func (ctx *JsContext) EnableRequire(fsys fs.FS) (err error) {
+	if fsys == nil {
+		fsys = os.DirFS(".")
+	}
+
+	ctx.lock()
+	defer ctx.unlock()
+
+	c := ctx.c
+	ctx.requireFS = fsys
+	compile := JsValue{ctx: c, v: C.newRequireCompile(c)}
+	defer C.JS_FreeValue(c, compile.v)
+
+	require, e := evalPrelude(c, "<require>", requirePrelude, requireResolve, compile, requireBuiltin)
+	if e != nil {
+		err = e
+		return
+	}
+	setGlobal(c, "require", require)
+	return
+}
+
+func requireResolve(ctx *JsContext, base string, name string) (filename string, err error) {
+	fsys := ctx.requireFS
+	var ok bool
+	if strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../") || strings.HasPrefix(name, "/") || name == "." || name == ".." {
+		p := name
+		if !strings.HasPrefix(name, "/") {
+			p = path.Join(path.Dir(base), name)
+		}
+		filename, ok = resolveModulePath(fsys, strings.TrimPrefix(path.Clean(p), "/"))
+	} else {
+		for dir := path.Dir(base); ; dir = path.Dir(dir) {
+			if path.Base(dir) != "node_modules" {
+				if filename, ok = resolveModulePath(fsys, path.Join(dir, "node_modules", name)); ok {
+					break
+				}
+			}
+			if dir == "." || dir == "/" {
+				break
+			}
+		}
+	}
+	if !ok {
+		err = fmt.Errorf("Cannot find module '%s'", name)
+	}
+	return
+}
+
+// try p as a file, then as a directory.
+func resolveModulePath(fsys fs.FS, p string) (string, bool) {
+	for _, f := range []string{p, p + ".js", p + ".json"} {
+		if isFile(fsys, f) {
+			return f, true
+		}
+	}
+	if b, err := fs.ReadFile(fsys, path.Join(p, "package.json")); err == nil {
+		var pkg struct {
+			Main string `json:"main"`
+		}
+		if json.Unmarshal(b, &pkg) == nil && len(pkg.Main) > 0 {
+			main := path.Join(p, pkg.Main)
+			for _, f := range []string{main, main + ".js", main + ".json", path.Join(main, "index.js"), path.Join(main, "index.json")} {
+				if isFile(fsys, f) {
+					return f, true
+				}
+			}
+		}
+	}
+	for _, f := range []string{path.Join(p, "index.js"), path.Join(p, "index.json")} {
+		if isFile(fsys, f) {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+func isFile(fsys fs.FS, name string) bool {
+	if !fs.ValidPath(name) {
+		return false
+	}
+	fi, err := fs.Stat(fsys, name)
+	return err == nil && !fi.IsDir()
+}
+
+func requireBuiltin(ctx *JsContext, name string) (exports interface{}, ok bool, err error) {
+	m, found := ctx.modules[name]
+	if !found {
+		return
+	}
+	if exports, err = m.getExports(); err != nil {
+		return
+	}
+	ok = true
+	return
+}
+
+// compile the module with the CommonJS wrapper, a module of JSON is parsed to its value.
+//export goRequireCompile
+func goRequireCompile(ctx *C.JSContext, this_val C.JSValueConst, argc C.int, argv *C.JSValueConst) (res C.JSValue) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = throwGoPanic(ctx, r)
+		}
+	}()
+
+	jsCtx := getJsContext(ctx)
+	if jsCtx == nil || argc < 1 {
+		return C.toException()
+	}
+	filename := toGoString(ctx, *argv)
+	b, err := fs.ReadFile(jsCtx.requireFS, filename)
+	if err != nil {
+		return throwGoError(ctx, err)
+	}
+
+	cName := C.CString(filename)
+	defer C.free(unsafe.Pointer(cName))
+	var source string
+	if strings.HasSuffix(filename, ".json") {
+		source = string(b)
+		cSource := C.CString(source)
+		defer C.free(unsafe.Pointer(cSource))
+		return C.JS_ParseJSON(ctx, cSource, C.size_t(len(source)), cName)
+	}
+
+	// keep the wrapper in the first line, so the line numbers are not changed
+	source = "(function (exports, require, module, __filename, __dirname) {" + string(b) + "\n})"
+	cSource := C.CString(source)
+	defer C.free(unsafe.Pointer(cSource))
+	return C.JS_Eval(ctx, cSource, C.size_t(len(source)), cName, C.JS_EVAL_TYPE_GLOBAL)
+}
@@ -0,0 +1,85 @@
+package quickjs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRequire(t *testing.T) {
+	fsys := fstest.MapFS{
+		"lib/math.js": {Data: []byte(`module.exports = {add: (a, b) => a + b};`)},
+		"lib/short.js": {Data: []byte(`exports.name = "short"; exports.self = this === exports;`)},
+		"lib/paths.js": {Data: []byte(`module.exports = [__filename, __dirname];`)},
+		"lib/uses-math.js": {Data: []byte(`module.exports = require("./math").add(1, 2);`)},
+		"lib/counter.js": {Data: []byte(`globalThis.loads = (globalThis.loads || 0) + 1; module.exports = {};`)},
+		"lib/broken.js": {Data: []byte(`globalThis.tries = (globalThis.tries || 0) + 1; throw new Error("broken");`)},
+		"lib/dir/index.js": {Data: []byte(`module.exports = "index";`)},
+		"config.json": {Data: []byte(`{"debug": true}`)},
+		"cycle/a.js": {Data: []byte(`exports.early = "a"; const b = require("./b"); exports.b = b.seen;`)},
+		"cycle/b.js": {Data: []byte(`exports.seen = require("./a").early;`)},
+		"node_modules/pkg/package.json": {Data: []byte(`{"main": "dist/main"}`)},
+		"node_modules/pkg/dist/main.js": {Data: []byte(`module.exports = "pkg";`)},
+		"app/node_modules/local/index.js": {Data: []byte(`module.exports = "local";`)},
+		"app/main.js": {Data: []byte(`module.exports = [require("local"), require("pkg")];`)},
+	}
+	tests := []struct {
+		name   string
+		script string
+		want   interface{}
+	}{
+		{"module.exports", `require("./lib/math.js").add(1, 2)`, int64(3)},
+		{"without extension", `require("./lib/math").add(2, 2)`, int64(4)},
+		{"exports", `const m = require("./lib/short"); [m.name, m.self]`, []interface{}{"short", true}},
+		{"filename", `require("./lib/paths")`, []interface{}{"lib/paths.js", "lib"}},
+		{"relative to module", `require("./lib/uses-math")`, int64(3)},
+		{"cached", `require("./lib/counter") === require("./lib/counter.js") && loads === 1`, true},
+		{"cache", `require("./lib/math"); Object.keys(require.cache).includes("lib/math.js")`, true},
+		{"not cached if throwing", `
+			for (let i = 0; i < 2; i++) {
+				try { require("./lib/broken") } catch (e) {}
+			}
+			tries`, int64(2)},
+		{"index", `require("./lib/dir")`, "index"},
+		{"json", `require("./config.json").debug`, true},
+		{"json without extension", `require("./config").debug`, true},
+		{"cycle", `require("./cycle/a").b`, "a"},
+		{"package main", `require("pkg")`, "pkg"},
+		{"nested node_modules", `require("./app/main")`, []interface{}{"local", "pkg"}},
+		{"builtin", `require("native").n`, "go"},
+		{"resolve", `[require.resolve("pkg"), require.resolve("./lib/math"), require.resolve("native")]`,
+			[]interface{}{"node_modules/pkg/dist/main.js", "lib/math.js", "native"}},
+		{"missing", `try { require("./missing") } catch (e) { e.message }`, "Cannot find module './missing'"},
+		{"not string", `try { require(1) } catch (e) { e instanceof TypeError }`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			if err := ctx.RegisterModule("native", map[string]interface{}{"n": "go"}); err != nil {
+				t.Fatalf("RegisterModule: %v", err)
+			}
+			if err := ctx.EnableRequire(fsys); err != nil {
+				t.Fatalf("EnableRequire: %v", err)
+			}
+			res, err := ctx.Eval(tt.script, nil)
+			if err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireSyntaxError(t *testing.T) {
+	ctx := newTestContext(t)
+	if err := ctx.EnableRequire(fstest.MapFS{"bad.js": {Data: []byte(`module.exports = ;`)}}); err != nil {
+		t.Fatalf("EnableRequire: %v", err)
+	}
+	_, err := ctx.Eval(`require("./bad")`, nil)
+	if err == nil || !strings.Contains(err.Error(), "SyntaxError") {
+		t.Errorf("got %v, want SyntaxError", err)
+	}
+}
@@ -0,0 +1,213 @@
+package quickjs
+
+// #include "go-proxy.h"
+import "C"
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"fmt"
+)
+
+const streamPrelude = `(function() {
+	class GoStream {
+		get [Symbol.toStringTag]() {
+			return "GoStream";
+		}
+	}
+	const toBytes = data => {
+		if (typeof data === "string" || data instanceof ArrayBuffer || ArrayBuffer.isView(data)) {
+			return data;
+		}
+		throw new TypeError("data must be a string, an ArrayBuffer or a view of it");
+	};
+
+	// make the wrapper of a golang stream, the funcs are null if they are not implemented.
+	return function(read, readAll, write, close) {
+		const s = new GoStream();
+		const closeStream = () => close !== null ? close() : undefined;
+		if (read !== null) {
+			s.read = function(n = 65536) {
+				return read(n).then(([data, eof]) => eof ? null : data);
+			};
+			s.readAll = function() {
+				return readAll();
+			};
+			s[Symbol.asyncIterator] = async function*() {
+				for (;;) {
+					const chunk = await s.read();
+					if (chunk === null) {
+						return;
+					}
+					yield chunk;
+				}
+			};
+			s.toReadableStream = function() {
+				if (typeof ReadableStream !== "function") {
+					throw new TypeError("ReadableStream is not defined");
+				}
+				return new ReadableStream({
+					async pull(controller) {
+						const chunk = await s.read();
+						if (chunk === null) {
+							controller.close();
+						} else {
+							controller.enqueue(chunk);
+						}
+					},
+					cancel: closeStream,
+				});
+			};
+		}
+		if (write !== null) {
+			s.write = function(data) {
+				return write(toBytes(data));
+			};
+			s.toWritableStream = function() {
+				if (typeof WritableStream !== "function") {
+					throw new TypeError("WritableStream is not defined");
+				}
+				return new WritableStream({
+					async write(chunk) {
+						await s.write(chunk);
+					},
+					close: closeStream,
+					abort: closeStream,
+				});
+			};
+		}
+		if (close !== null) {
+			s.close = function() {
+				return close();
+			};
+		}
+		return Object.freeze(s);
+	};
+})`
+
+// IOStream is an io.Reader, io.Writer or io.Closer marked by Stream.
+type IOStream struct {
+	v interface{}
+}
+
+// Stream marks v, which implements io.Reader, io.Writer or both, to be converted to an object
+// with the methods returning Promises: `read(n)` resolved with an Uint8Array of at most n bytes,
+// or null at EOF, `readAll()` resolved with an Uint8Array, and async iteration over the chunks
+// if v is an io.Reader; `write(data)` resolved with the number of bytes written if v is an
+// io.Writer, where data is a string or a buffer; `close()` if v is an io.Closer. `toReadableStream()`
+// and `toWritableStream()` make the WHATWG streams if `ReadableStream` and `WritableStream` are
+// defined by the global object. The IO is done in goroutines one at a time, like the async
+// golang funcs, so the Promises are settled by the event loop. `close()` isn't waiting for the
+// IO pending, which is interrupted if v supports it, like a pipe or a connection.
+func Stream(v interface{}) IOStream {
+	return IOStream{v: v}
+}
+
+// the reads and writes of a stream are serialized by mu.
+type goStream struct {
+	mu sync.Mutex
+	readErr error // returned by Read with the data, it's kept for the next read
+}
+
+func makeStream(ctx *C.JSContext, stream IOStream) (C.JSValue, error) {
+	jsCtx := getJsContext(ctx)
+	if jsCtx == nil {
+		return C.toUndefined(), nil
+	}
+	if stream.v == nil {
+		return C.toNull(), nil
+	}
+	r, isReader := stream.v.(io.Reader)
+	w, isWriter := stream.v.(io.Writer)
+	c, isCloser := stream.v.(io.Closer)
+	if !isReader && !isWriter {
+		return C.toUndefined(), fmt.Errorf("io.Reader or io.Writer expected, but got %T", stream.v)
+	}
+	factory, err := jsCtx.heldPrelude(&jsCtx.streamFactory, "<stream>", streamPrelude)
+	if err != nil {
+		return C.toUndefined(), err
+	}
+
+	s := &goStream{}
+	var read, readAll, write, close interface{}
+	if isReader {
+		read, readAll = Async(s.read(r)), Async(s.readAll(r))
+	}
+	if isWriter {
+		write = Async(s.write(w))
+	}
+	if isCloser {
+		close = Async(s.close(c))
+	}
+	res, err := callFunc(ctx, factory, read, readAll, write, close)
+	if err == nil && C.JS_IsException(res) != 0 {
+		err = fromJsException(ctx)
+	}
+	return res, err
+}
+
+// read at most n bytes, eof is true if nothing can be read any more.
+func (s *goStream) read(r io.Reader) func(context.Context, int) (Uint8Array, bool, error) {
+	return func(goCtx context.Context, n int) (data Uint8Array, eof bool, err error) {
+		if n <= 0 {
+			err = fmt.Errorf("the size to read must be positive")
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err = goCtx.Err(); err != nil {
+			return
+		}
+		e := s.readErr
+		if e == nil {
+			buf := make([]byte, n)
+			var l int
+			if l, e = r.Read(buf); l > 0 {
+				// the error is returned by the next read
+				s.readErr = e
+				return buf[:l], false, nil
+			}
+		}
+		if errors.Is(e, io.EOF) {
+			return nil, true, nil
+		}
+		return nil, false, e
+	}
+}
+
+func (s *goStream) readAll(r io.Reader) func(context.Context) (Uint8Array, error) {
+	return func(goCtx context.Context) (Uint8Array, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err := goCtx.Err(); err != nil {
+			return nil, err
+		}
+		if e := s.readErr; e != nil {
+			if errors.Is(e, io.EOF) {
+				return Uint8Array{}, nil
+			}
+			return nil, e
+		}
+		return io.ReadAll(r)
+	}
+}
+
+func (s *goStream) write(w io.Writer) func(context.Context, []byte) (int, error) {
+	return func(goCtx context.Context, data []byte) (int, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err := goCtx.Err(); err != nil {
+			return 0, err
+		}
+		return w.Write(data)
+	}
+}
+
+// close is not serialized with the reads and writes, so it can interrupt those pending,
+// such as reading a pipe or a connection.
+func (s *goStream) close(c io.Closer) func() error {
+	return func() error {
+		return c.Close()
+	}
+}
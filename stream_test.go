@@ -0,0 +1,268 @@
+package quickjs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// a reader returning the chunks one by one, and err after them. If errWithLast is true, err is
+// returned with the last chunk only once, and then io.EOF.
+type chunkReader struct {
+	chunks      []string
+	err         error
+	errWithLast bool
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		if r.errWithLast {
+			return 0, io.EOF
+		}
+		return 0, r.err
+	}
+	n := copy(p, r.chunks[0])
+	if n < len(r.chunks[0]) {
+		r.chunks[0] = r.chunks[0][n:]
+	} else {
+		r.chunks = r.chunks[1:]
+	}
+	if len(r.chunks) == 0 && r.errWithLast {
+		return n, r.err
+	}
+	return n, nil
+}
+
+// a buffer recording whether it's closed.
+type closeBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closeBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestStreamRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       io.Reader
+		script  string
+		want    interface{}
+		wantErr string
+	}{
+		{name: "read", r: &chunkReader{chunks: []string{"ab", "c"}, err: io.EOF}, script: `
+			(async () => {
+				const got = [];
+				for (let chunk; (chunk = await s.read()) !== null; ) {
+					got.push(dec.decode(chunk));
+				}
+				return got;
+			})()`, want: []interface{}{"ab", "c"}},
+		{name: "read n", r: strings.NewReader("abcde"), script: `s.read(2).then(b => [b instanceof Uint8Array, dec.decode(b)])`,
+			want: []interface{}{true, "ab"}},
+		{name: "read nonpositive", r: strings.NewReader("abc"), script: `s.read(0)`, wantErr: "positive"},
+		{name: "readAll", r: &chunkReader{chunks: []string{"ab", "cd", "e"}, err: io.EOF}, script: `s.readAll().then(b => dec.decode(b))`,
+			want: "abcde"},
+		{name: "async iteration", r: &chunkReader{chunks: []string{"x", "y", "z"}, err: io.EOF}, script: `
+			(async () => {
+				let all = "";
+				for await (const chunk of s) {
+					all += dec.decode(chunk);
+				}
+				return all;
+			})()`, want: "xyz"},
+		{name: "read error", r: &chunkReader{chunks: []string{"a"}, err: errors.New("broken pipe")}, script: `
+			(async () => {
+				const first = dec.decode(await s.read());
+				try {
+					await s.read();
+				} catch (e) {
+					return [first, e.message];
+				}
+			})()`, want: []interface{}{"a", "broken pipe"}},
+		{name: "read error with data", r: &chunkReader{chunks: []string{"a"}, err: errors.New("broken pipe"), errWithLast: true}, script: `
+			(async () => {
+				const first = dec.decode(await s.read());
+				try {
+					await s.read();
+				} catch (e) {
+					return [first, e.message];
+				}
+			})()`, want: []interface{}{"a", "broken pipe"}},
+		{name: "EOF with data", r: &chunkReader{chunks: []string{"ab", "c"}, err: io.EOF, errWithLast: true}, script: `
+			(async () => {
+				const got = [];
+				for await (const chunk of s) {
+					got.push(dec.decode(chunk));
+				}
+				return got;
+			})()`, want: []interface{}{"ab", "c"}},
+		{name: "readAll error", r: &chunkReader{err: errors.New("broken pipe")}, script: `s.readAll()`, wantErr: "broken pipe"},
+		{name: "readAll error with data", r: &chunkReader{chunks: []string{"a"}, err: errors.New("broken pipe"), errWithLast: true},
+			script: `s.read().then(() => s.readAll())`, wantErr: "broken pipe"},
+		{name: "reader only", r: strings.NewReader(""), script: `[typeof s.read, typeof s.write, typeof s.close, String(s)]`,
+			want: []interface{}{"function", "undefined", "undefined", "[object GoStream]"}},
+		{name: "frozen", r: strings.NewReader(""), script: `"use strict"; s.read = null`, wantErr: "TypeError"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			if err := ctx.EnableWebGlobals(); err != nil {
+				t.Fatalf("EnableWebGlobals: %v", err)
+			}
+			env := map[string]interface{}{"s": Stream(tt.r)}
+			if _, err := ctx.Eval(`var dec = new TextDecoder()`, nil); err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			res, err := ctx.EvalAwait(context.Background(), tt.script, env)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EvalAwait: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamWrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		want    string // written to the buffer
+		closed  bool
+		wantErr string
+	}{
+		{name: "string", script: `s.write("héllo")`, want: "héllo"},
+		{name: "buffers", script: `
+			s.write(new Uint8Array([0x61, 0x62]))
+				.then(() => s.write(new Uint8Array([0x63, 0x64, 0x65]).subarray(1, 2)))
+				.then(() => s.write(new Uint8Array([0x65]).buffer))`, want: "abde"},
+		{name: "bytes written", script: `s.write("abc").then(n => { if (n !== 3) throw new Error("wrote " + n); })`, want: "abc"},
+		{name: "close", script: `s.write("a").then(() => s.close())`, want: "a", closed: true},
+		{name: "wrong type", script: `s.write(1)`, wantErr: "TypeError"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			buf := &closeBuffer{}
+			_, err := ctx.EvalAwait(context.Background(), tt.script, map[string]interface{}{"s": Stream(buf)})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EvalAwait: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q written, want %q", got, tt.want)
+			}
+			if buf.closed != tt.closed {
+				t.Errorf("got closed %v, want %v", buf.closed, tt.closed)
+			}
+		})
+	}
+}
+
+func TestStreamBadValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       interface{}
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"neither reader nor writer", 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			res, err := ctx.Eval(`s`, map[string]interface{}{"s": Stream(tt.v)})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && res != nil {
+				t.Errorf("got %#v, want nil", res)
+			}
+		})
+	}
+}
+
+// a reader telling when it's blocked in Read.
+type blockedReader struct {
+	io.ReadCloser
+	once    sync.Once
+	reading chan struct{}
+}
+
+func (r *blockedReader) Read(p []byte) (int, error) {
+	r.once.Do(func() { close(r.reading) })
+	return r.ReadCloser.Read(p)
+}
+
+// closing a stream interrupts the read pending, instead of waiting for it.
+func TestStreamClosedWhileReading(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{"read", `
+			(async () => {
+				const pending = s.read();
+				await reading();
+				await s.close();
+				try {
+					await pending;
+				} catch (e) {
+					return e.message;
+				}
+			})()`},
+		{"readAll", `
+			(async () => {
+				const pending = s.readAll();
+				await reading();
+				await s.close();
+				try {
+					await pending;
+				} catch (e) {
+					return e.message;
+				}
+			})()`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			pr, pw := io.Pipe()
+			defer pw.Close()
+			r := &blockedReader{ReadCloser: pr, reading: make(chan struct{})}
+			env := map[string]interface{}{
+				"s":       Stream(r),
+				"reading": Async(func() { <-r.reading }),
+			}
+			goCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			res, err := ctx.EvalAwait(goCtx, tt.script, env)
+			if err != nil {
+				t.Fatalf("EvalAwait: %v", err)
+			}
+			if res != io.ErrClosedPipe.Error() {
+				t.Errorf("got %#v, want %q", res, io.ErrClosedPipe.Error())
+			}
+		})
+	}
+}
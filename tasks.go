@@ -0,0 +1,94 @@
+package quickjs
+
+import (
+	"context"
+	"sync"
+)
+
+// taskQueue passes the completions of the work done in other goroutines, such as
+// async golang funcs and timers, to the thread owning the context.
+type taskQueue struct {
+	mu      sync.Mutex
+	tasks   []func() error
+	pending int // the works started but not posted yet
+	notify  chan struct{}
+}
+
+func newTaskQueue() *taskQueue {
+	return &taskQueue{notify: make(chan struct{}, 1)}
+}
+
+// a work is started, post() must be called once when it's done.
+func (q *taskQueue) start() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending += 1
+}
+
+// post the task run on the owning thread, it can be called in any goroutine.
+func (q *taskQueue) post(task func() error) {
+	q.mu.Lock()
+	q.tasks = append(q.tasks, task)
+	q.pending -= 1
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// a work is stopped without posting a task.
+func (q *taskQueue) cancel() {
+	q.mu.Lock()
+	q.pending -= 1
+	q.mu.Unlock()
+
+	// wake up the waiting one to check whether it's still busy
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *taskQueue) take() (tasks []func() error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	tasks, q.tasks = q.tasks, nil
+	return
+}
+
+func (q *taskQueue) hasReady() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.tasks) > 0
+}
+
+// whether there are tasks posted or to be posted.
+func (q *taskQueue) busy() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.tasks) > 0 || q.pending > 0
+}
+
+// wait until a task is posted or goCtx is done.
+func (q *taskQueue) wait(goCtx context.Context) error {
+	select {
+	case <-q.notify:
+		return nil
+	case <-goCtx.Done():
+		return goCtx.Err()
+	}
+}
+
+// run the tasks posted, ran is false if there's no task. The error of a task
+// is returned after all the tasks are run.
+func (ctx *jsContext) runTasks() (ran bool, err error) {
+	tasks := ctx.tasks.take()
+	for _, task := range tasks {
+		if e := task(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return len(tasks) > 0, err
+}
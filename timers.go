@@ -0,0 +1,233 @@
+package quickjs
+
+/*
+#include "go-proxy.h"
+
+static JSValue callMicrotask(JSContext *ctx, int argc, JSValueConst *argv) {
+	return JS_Call(ctx, argv[0], JS_UNDEFINED, 0, NULL);
+}
+static int enqueueMicrotask(JSContext *ctx, JSValueConst fn) {
+	return JS_EnqueueJob(ctx, callMicrotask, 1, &fn);
+}
+*/
+import "C"
+import (
+	"context"
+	"time"
+	"fmt"
+)
+
+// Clock is used by the timers of the context, which can be replaced with a fake one in tests.
+type Clock interface {
+	// AfterFunc calls f in its own goroutine after duration d.
+	AfterFunc(d time.Duration, f func()) ClockTimer
+}
+
+// ClockTimer is the timer returned by Clock.AfterFunc.
+type ClockTimer interface {
+	// Stop prevents the timer from firing, it returns false if the timer has fired or been stopped.
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	return time.AfterFunc(d, f)
+}
+
+type jsTimer struct {
+	fn       uint32   // id of the held callback
+	args     []uint32 // ids of the held args
+	interval time.Duration
+	timer    ClockTimer
+}
+
+// SetClock sets the clock used by the timers, nil to restore the real one.
+func (ctx *JsContext) SetClock(clock Clock) {
+	ctx.lock()
+	defer ctx.unlock()
+	if clock == nil {
+		clock = realClock{}
+	}
+	ctx.clock = clock
+}
+
+// Run runs the event loop until there is nothing to do: no pending jobs, timers, or
+// async golang funcs not returned. If goCtx is done, all the timers are cleared and
+// goCtx.Err() is returned. The exception thrown by a callback stops the loop.
+func (ctx *JsContext) Run(goCtx context.Context) (err error) {
+	ctx.lock()
+	defer ctx.unlock()
+	defer ctx.withGoContext(goCtx)()
+
+	for {
+		if err = ctx.runPendingJobs(); err != nil {
+			return
+		}
+		if !ctx.tasks.busy() {
+			return
+		}
+
+		// the context can be used by others while waiting
+		ctx.unlock()
+		err = ctx.tasks.wait(goCtx)
+		ctx.lock()
+		if err != nil {
+			ctx.clearTimers()
+			return
+		}
+	}
+}
+
+// ClearTimers cancels all the timers set by the scripts.
+func (ctx *JsContext) ClearTimers() {
+	ctx.lock()
+	defer ctx.unlock()
+	ctx.clearTimers()
+}
+
+// add setTimeout, setInterval, clearTimeout, clearInterval and queueMicrotask to the global object.
+func (ctx *JsContext) addTimerFuncs() (err error) {
+	return ctx.setEnv(map[string]interface{}{
+		"setTimeout": func(ci *CallInfo) (int, error) {
+			return ci.JsContext.setTimer(ci, false)
+		},
+		"setInterval": func(ci *CallInfo) (int, error) {
+			return ci.JsContext.setTimer(ci, true)
+		},
+		"clearTimeout": clearTimer,
+		"clearInterval": clearTimer,
+		"queueMicrotask": queueMicrotask,
+	})
+}
+
+func (ctx *jsContext) setTimer(ci *CallInfo, repeat bool) (id int, err error) {
+	if ci.Argc < 1 || !ci.Args[0].IsFunction() {
+		err = fmt.Errorf("callback expected to be a function")
+		return
+	}
+	var delay time.Duration
+	if ci.Argc > 1 {
+		if ms, ok := toFloat(ci.Args[1]); ok && ms > 0 {
+			delay = time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+
+	c := ctx.c
+	t := &jsTimer{fn: ctx.hold(C.JS_DupValue(c, ci.Args[0].v))}
+	for i:=2; i<ci.Argc; i++ {
+		t.args = append(t.args, ctx.hold(C.JS_DupValue(c, ci.Args[i].v)))
+	}
+	if repeat {
+		t.interval = delay
+		if t.interval < time.Millisecond {
+			t.interval = time.Millisecond
+		}
+	}
+	ctx.timerSeq += 1
+	id = ctx.timerSeq
+	ctx.timers[id] = t
+	ctx.scheduleTimer(id, t, delay)
+	return
+}
+
+func (ctx *jsContext) scheduleTimer(id int, t *jsTimer, delay time.Duration) {
+	ctx.tasks.start()
+	t.timer = ctx.clock.AfterFunc(delay, func() {
+		ctx.tasks.post(func() error {
+			return ctx.fireTimer(id)
+		})
+	})
+}
+
+// call the callback of the timer, run on the thread of JS.
+func (ctx *jsContext) fireTimer(id int) error {
+	t, ok := ctx.timers[id]
+	if !ok || ctx.c == nil {
+		// cleared or closed
+		return nil
+	}
+	if t.interval == 0 {
+		delete(ctx.timers, id)
+		defer ctx.releaseTimer(t)
+	}
+
+	// the callback and the args are referenced during the call, for they are released
+	// if the callback clears the timer
+	c := ctx.c
+	fn := C.JS_DupValue(c, ctx.held[t.fn])
+	defer C.JS_FreeValue(c, fn)
+	argv := make([]C.JSValue, len(t.args) + 1) // not empty, so &argv[0] is valid
+	for i, arg := range t.args {
+		argv[i] = C.JS_DupValue(c, ctx.held[arg])
+		defer C.JS_FreeValue(c, argv[i])
+	}
+	r := C.JS_Call(c, fn, C.toUndefined(), C.int(len(t.args)), &argv[0])
+	if C.JS_IsException(r) != 0 {
+		return fromJsException(c)
+	}
+	C.JS_FreeValue(c, r)
+
+	if _, ok = ctx.timers[id]; ok && t.interval > 0 {
+		// not cleared by the callback
+		ctx.scheduleTimer(id, t, t.interval)
+	}
+	return nil
+}
+
+func clearTimer(ctx *JsContext, id interface{}) {
+	switch n := id.(type) {
+	case int64:
+		ctx.clearTimer(int(n))
+	case float64:
+		ctx.clearTimer(int(n))
+	}
+}
+
+func (ctx *jsContext) clearTimer(id int) {
+	t, ok := ctx.timers[id]
+	if !ok {
+		return
+	}
+	delete(ctx.timers, id)
+	if t.timer.Stop() {
+		// the task will not be posted
+		ctx.tasks.cancel()
+	}
+	ctx.releaseTimer(t)
+}
+
+func (ctx *jsContext) clearTimers() {
+	for id := range ctx.timers {
+		ctx.clearTimer(id)
+	}
+}
+
+func (ctx *jsContext) releaseTimer(t *jsTimer) {
+	ctx.release(t.fn)
+	for _, arg := range t.args {
+		ctx.release(arg)
+	}
+}
+
+func queueMicrotask(ci *CallInfo) error {
+	if ci.Argc < 1 || !ci.Args[0].IsFunction() {
+		return fmt.Errorf("callback expected to be a function")
+	}
+	C.enqueueMicrotask(ci.Args[0].ctx, ci.Args[0].v)
+	return nil
+}
+
+func toFloat(v JsValue) (f float64, ok bool) {
+	goVal, err := v.Value()
+	if err != nil {
+		return
+	}
+	switch n := goVal.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return
+}
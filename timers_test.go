@@ -0,0 +1,269 @@
+package quickjs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimers(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{
+			name: "timeouts in order of delay",
+			script: `var log = [];
+				setTimeout(() => log.push("b"), 20);
+				setTimeout(() => log.push("a"), 1);
+				queueMicrotask(() => log.push("micro"));`,
+			want: "micro,a,b",
+		},
+		{
+			name: "args passed to callback",
+			script: `var log = [];
+				setTimeout((x, y) => log.push(x + y), 0, 1, 2);`,
+			want: "3",
+		},
+		{
+			name: "cleared timeout",
+			script: `var log = [];
+				const id = setTimeout(() => log.push("no"), 1);
+				clearTimeout(id);
+				setTimeout(() => log.push("yes"), 2);`,
+			want: "yes",
+		},
+		{
+			name: "interval cleared after 3 times",
+			script: `var log = [];
+				let n = 0;
+				const id = setInterval(() => {
+					log.push(++n);
+					if (n === 3) clearInterval(id);
+				}, 1);`,
+			want: "1,2,3",
+		},
+		{
+			name: "interval cleared by another timer",
+			script: `var log = [];
+				const id = setInterval(() => log.push("tick"), 1);
+				setTimeout(() => { clearInterval(id); log.push("done"); }, 15);`,
+			want: "done",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			if _, err := ctx.Eval(tt.script, nil); err != nil {
+				t.Fatalf("eval: %v", err)
+			}
+			if err := ctx.Run(context.Background()); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			got, err := ctx.Eval(`log.filter(x => x !== "tick").join(",")`, nil)
+			if err != nil || got != tt.want {
+				t.Errorf("got %v (%v), want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// the callback and its args are freed by clearInterval() called by the callback itself,
+// they must be kept alive until the callback returns.
+func TestIntervalClearedByItself(t *testing.T) {
+	ctx := newTestContext(t)
+	_, err := ctx.EvalModule("clear-itself", `
+		import * as std from "std";
+		let n = 0;
+		const id = setInterval(function(arg) {
+			clearInterval(id);
+			std.gc();
+			globalThis.result = [arg.value, typeof arguments[0], ++n];
+		}, 1, {value: "kept"});
+	`)
+	if err != nil {
+		t.Fatalf("eval: %v", err)
+	}
+	if err = ctx.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got, err := ctx.Eval(`result.join(",")`, nil)
+	if err != nil || got != "kept,object,1" {
+		t.Errorf("got %v (%v)", got, err)
+	}
+}
+
+func TestTimerException(t *testing.T) {
+	ctx := newTestContext(t)
+	ctx.Eval(`setTimeout(() => { throw new Error("boom") }, 1)`, nil)
+	if err := ctx.Run(context.Background()); err == nil || err.Error() == "" {
+		t.Fatalf("error expected, got %v", err)
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	ctx := newTestContext(t)
+	ctx.Eval(`var fired = false; setTimeout(() => { fired = true }, 60000)`, nil)
+	goCtx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := ctx.Run(goCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want deadline exceeded", err)
+	}
+	// the timers are cleared, so Run returns at once
+	if err := ctx.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if fired, _ := ctx.GetGlobal("fired"); fired != false {
+		t.Errorf("timer fired after Run was canceled")
+	}
+}
+
+func TestClearTimersFromGo(t *testing.T) {
+	ctx := newTestContext(t)
+	ctx.Eval(`setTimeout(() => {}, 60000); setInterval(() => {}, 60000)`, nil)
+	done := make(chan error, 1)
+	go func() {
+		done <- ctx.Run(context.Background())
+	}()
+	time.Sleep(10 * time.Millisecond)
+	ctx.ClearTimers()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run is not woken up by ClearTimers")
+	}
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Duration
+	f     func()
+	done  bool
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	if t.done {
+		return false
+	}
+	t.done = true
+	return true
+}
+
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Duration
+	timers []*fakeTimer
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, at: c.now + d, f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// fire the timers due in d, in order of time.
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now += d
+	var due []*fakeTimer
+	for _, t := range c.timers {
+		if !t.done && t.at <= c.now {
+			t.done = true
+			due = append(due, t)
+		}
+	}
+	c.mu.Unlock()
+	sort.SliceStable(due, func(i, j int) bool { return due[i].at < due[j].at })
+	for _, t := range due {
+		t.f()
+	}
+}
+
+func TestSetClock(t *testing.T) {
+	ctx := newTestContext(t)
+	clock := &fakeClock{}
+	ctx.SetClock(clock)
+	ctx.Eval(`var log = []; setTimeout(() => log.push("1h"), 3600 * 1000); setTimeout(() => log.push("1s"), 1000)`, nil)
+
+	clock.advance(time.Second)
+	if err := ctx.RunPendingJobs(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ctx.Eval(`log.join(",")`, nil); got != "1s" {
+		t.Fatalf("after 1s: got %v", got)
+	}
+	clock.advance(time.Hour)
+	if err := ctx.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ctx.Eval(`log.join(",")`, nil); got != "1s,1h" {
+		t.Fatalf("after 1h: got %v", got)
+	}
+}
+
+// the timers and async golang funcs awaited at the top level of modules are run by the Go loop.
+func TestModuleTopLevelAwait(t *testing.T) {
+	sleep := Async(func(ms int) string {
+		time.Sleep(time.Duration(ms) * time.Millisecond)
+		return "slept"
+	})
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{"timer", `await new Promise(r => setTimeout(r, 10, "timer")).then(v => globalThis.result = v);`, "timer"},
+		{"async func", `globalThis.result = await sleep(10);`, "slept"},
+		{"imported", `import "./dep.js"; globalThis.result += "!";`, "dep!"},
+	}
+	evals := map[string]func(ctx *JsContext, script string) error{
+		"Eval": func(ctx *JsContext, script string) error {
+			_, err := ctx.Eval(`export {};`+script, nil)
+			return err
+		},
+		"EvalModule": func(ctx *JsContext, script string) error {
+			_, err := ctx.EvalModule("main.js", script)
+			return err
+		},
+	}
+	for evalName, eval := range evals {
+		for _, tt := range tests {
+			t.Run(evalName+"/"+tt.name, func(t *testing.T) {
+				ctx := newTestContext(t)
+				ctx.SetModuleLoader(NewMapModuleLoader(map[string]string{
+					"dep.js": `globalThis.result = await new Promise(r => setTimeout(r, 10, "dep"));`,
+				}))
+				if _, err := ctx.Eval(`0`, map[string]interface{}{"sleep": sleep}); err != nil {
+					t.Fatalf("Eval: %v", err)
+				}
+				if err := eval(ctx, tt.script); err != nil {
+					t.Fatalf("%s: %v", evalName, err)
+				}
+				if got, _ := ctx.GetGlobal("result"); got != tt.want {
+					t.Errorf("got %v, want %q", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestModuleTopLevelAwaitCanceled(t *testing.T) {
+	ctx := newTestContext(t)
+	goCtx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := ctx.EvalContext(goCtx, `export {}; await new Promise(r => setTimeout(r, 60000));`, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want deadline exceeded", err)
+	}
+}
@@ -8,6 +8,20 @@ static JSAtom getAtom(struct JSPropertyEnum *atom, int i) {
 static int jsValueGetTag(JSValueConst v) {
 	return JS_VALUE_GET_TAG(v);
 }
+static void *jsObjectPtr(JSValueConst v) {
+	return JS_VALUE_GET_PTR(v);
+}
+static JSValue newUint8Array(JSContext *ctx, const uint8_t *buf, size_t len) {
+	JSValue ab = JS_NewArrayBufferCopy(ctx, buf, len);
+	if (JS_IsException(ab)) {
+		return ab;
+	}
+	// the constructor expects the args offset and length
+	JSValue args[3] = {ab, JS_UNDEFINED, JS_UNDEFINED};
+	JSValue ta = JS_NewTypedArray(ctx, 3, args, JS_TYPED_ARRAY_UINT8);
+	JS_FreeValue(ctx, ab);
+	return ta;
+}
 */
 import "C"
 import (
@@ -18,6 +32,29 @@ import (
 
 // 把JS的值转成golang的值，不释放jsVal的空间
 func fromJsValue(ctx *C.JSContext, jsVal C.JSValue) (goVal interface{}, err error) {
+	return fromJsValueIn(ctx, jsVal, nil)
+}
+
+// the arrays and objects being converted, to stop at the cyclic references
+type jsObjectPath map[unsafe.Pointer]struct{}
+
+func (path *jsObjectPath) enter(jsVal C.JSValue) error {
+	p := C.jsObjectPtr(jsVal)
+	if _, ok := (*path)[p]; ok {
+		return fmt.Errorf("cyclic object value")
+	}
+	if *path == nil {
+		*path = make(jsObjectPath)
+	}
+	(*path)[p] = struct{}{}
+	return nil
+}
+
+func (path jsObjectPath) leave(jsVal C.JSValue) {
+	delete(path, C.jsObjectPtr(jsVal))
+}
+
+func fromJsValueIn(ctx *C.JSContext, jsVal C.JSValue, path jsObjectPath) (goVal interface{}, err error) {
 	switch {
 	case C.JS_IsException(jsVal) != 0:
 		err = fromJsException(ctx)
@@ -50,12 +87,29 @@ func fromJsValue(ctx *C.JSContext, jsVal C.JSValue) (goVal interface{}, err erro
 		C.JS_FreeCString(ctx, cstr)
 		return
 	case C.JS_IsArray(ctx, jsVal) != 0:
-		return fromJsArray(ctx, jsVal)
+		if err = path.enter(jsVal); err != nil {
+			return
+		}
+		defer path.leave(jsVal)
+		return fromJsArray(ctx, jsVal, func(eJsV C.JSValue) (interface{}, error) {
+			return fromJsValueIn(ctx, eJsV, path)
+		})
 	case C.JS_IsFunction(ctx, jsVal) != 0:
 		goVal = fromJsFunc(ctx, jsVal)
 		return
 	case C.JS_IsObject(jsVal) != 0:
-		return fromJsObject(ctx, jsVal)
+		if v, ok := getTargetValue(ctx, jsVal); ok {
+			// a Go value wrapped by makeGoObject, give the original one back
+			goVal = v
+			return
+		}
+		if err = path.enter(jsVal); err != nil {
+			return
+		}
+		defer path.leave(jsVal)
+		return fromJsObject(ctx, jsVal, func(eJsV C.JSValue) (interface{}, error) {
+			return fromJsValueIn(ctx, eJsV, path)
+		})
 	default:
 		err = fmt.Errorf("unsupported type")
 		return
@@ -76,7 +130,33 @@ func makeBytes(ctx *C.JSContext, b []byte) C.JSValue {
 	return C.JS_NewStringLen(ctx, cstr, C.size_t(sLen))
 }
 
-func fromJsArray(ctx *C.JSContext, jsVal C.JSValue) (goVal interface{}, err error) {
+// Uint8Array is converted to a JS Uint8Array with a copy of it, while []byte is converted to a string.
+type Uint8Array []byte
+
+// copy the bytes of an ArrayBuffer, a typed array or a DataView, which is converted
+// only for the args of golang funcs with type []byte.
+func getBufferBytes(ctx *C.JSContext, jsVal C.JSValueConst) (b []byte, ok bool) {
+	var p *C.uint8_t
+	var size C.size_t
+	if C.JS_GetBufferBytes(ctx, &p, &size, jsVal) == 0 {
+		return
+	}
+	if size == 0 {
+		return []byte{}, true
+	}
+	return C.GoBytes(unsafe.Pointer(p), C.int(size)), true
+}
+
+// make a Uint8Array with a copy of b
+func makeUint8Array(ctx *C.JSContext, b []byte) C.JSValue {
+	var cstr *C.char
+	var bLen C.int
+	getBytesPtrLen(b, &cstr, &bLen)
+	return C.newUint8Array(ctx, (*C.uint8_t)(unsafe.Pointer(cstr)), C.size_t(bLen))
+}
+
+// the elements are converted by elem
+func fromJsArray(ctx *C.JSContext, jsVal C.JSValue, elem func(C.JSValue) (interface{}, error)) (goVal interface{}, err error) {
 	arrLen := getPropertyStr(ctx, jsVal, "length\x00")
 	defer C.JS_FreeValue(ctx, arrLen)
 
@@ -99,7 +179,7 @@ func fromJsArray(ctx *C.JSContext, jsVal C.JSValue) (goVal interface{}, err erro
 			// err = fmt.Errorf("exception when get %d element of array\n", i)
 			return
 		}
-		ev, e := fromJsValue(ctx, eJsV)
+		ev, e := elem(eJsV)
 		C.JS_FreeValue(ctx, eJsV)
 		if e != nil {
 			err = e
@@ -111,7 +191,8 @@ func fromJsArray(ctx *C.JSContext, jsVal C.JSValue) (goVal interface{}, err erro
 	return
 }
 
-func fromJsObject(ctx *C.JSContext, jsVal C.JSValue) (goVal interface{}, err error) {
+// the property values are converted by elem
+func fromJsObject(ctx *C.JSContext, jsVal C.JSValue, elem func(C.JSValue) (interface{}, error)) (goVal interface{}, err error) {
 	var tab_atom *C.JSPropertyEnum
 	var tab_atom_count C.uint32_t
 	if C.JS_GetOwnPropertyNames(ctx, &tab_atom, &tab_atom_count, jsVal, C.JS_GPN_STRING_MASK | C.JS_GPN_SYMBOL_MASK | C.JS_GPN_ENUM_ONLY) == -1 {
@@ -128,9 +209,14 @@ func fromJsObject(ctx *C.JSContext, jsVal C.JSValue) (goVal interface{}, err err
 		a := C.getAtom(tab_atom, C.int(i))
 		eJsV := C.JS_GetProperty(ctx, jsVal, a)
 		C.JS_FreeAtom(ctx, a)
-		ev, e := fromJsValue(ctx, eJsV)
+		ev, e := elem(eJsV)
 		C.JS_FreeValue(ctx, eJsV)
 		if e != nil {
+			// a cyclic reference or an exception, free the atoms left
+			for j:=i+1; j<count; j++ {
+				C.JS_FreeAtom(ctx, C.getAtom(tab_atom, C.int(j)))
+			}
+			C.js_free(ctx, unsafe.Pointer(tab_atom))
 			err = e
 			return
 		}
@@ -155,10 +241,16 @@ func getPropertyStr(ctx *C.JSContext, jsVal C.JSValue, czStr string) C.JSValue {
 	return C.JS_GetPropertyStr(ctx, jsVal, prop)
 }
 
-func getExceptionStr(ctx *C.JSContext, exVal C.JSValue) string {
-	name := getPropertyStr(ctx, exVal, "name\x00")
-	defer C.JS_FreeValue(ctx, name)
-	str := C.JS_ToCString(ctx, name)
+// set property of jsVal, the ownership of val is taken
+func setPropertyStr(ctx *C.JSContext, jsVal C.JSValue, czStr string, val C.JSValue) {
+	var prop *C.char
+	getStrPtr(&czStr, &prop)
+	C.JS_SetPropertyStr(ctx, jsVal, prop, val)
+}
+
+// convert any JS value to string as JS `String(v)`
+func toGoString(ctx *C.JSContext, jsVal C.JSValue) string {
+	str := C.JS_ToCString(ctx, jsVal)
 	if str == (*C.char)(unsafe.Pointer(nil)) {
 		return ""
 	}
@@ -167,27 +259,20 @@ func getExceptionStr(ctx *C.JSContext, exVal C.JSValue) string {
 	return C.GoString(str)
 }
 
-func dumpException(ctx *C.JSContext, exVal C.JSValue) {
-	stack := getPropertyStr(ctx, exVal, "stack\x00")
-	defer C.JS_FreeValue(ctx, stack)
-	if C.JS_IsUndefined(stack) == 0 && C.JS_IsNull(stack) == 0 {
-		s := C.JS_ToCString(ctx, stack)
-		if s != (*C.char)(unsafe.Pointer(nil)) {
-			println("Stack trace", C.GoString(s))
-			C.JS_FreeCString(ctx, s)
-		}
-	}
-}
-
 func fromJsException(ctx *C.JSContext) (err error) {
 	exVal := C.JS_GetException(ctx)
-	if C.JS_IsError(ctx, exVal) == 0 {
-		return nil
-	}
 	defer C.JS_FreeValue(ctx, exVal)
 
-	dumpException(ctx, exVal)
-	exceptionStr := getExceptionStr(ctx, exVal)
+	if C.JS_IsError(ctx, exVal) != 0 {
+		// the error thrown by throwGoError, return the golang error itself
+		goErr := getPropertyStr(ctx, exVal, "goError\x00")
+		v, _ := getTargetValue(ctx, goErr)
+		C.JS_FreeValue(ctx, goErr)
+		if e, ok := v.(error); ok {
+			return e
+		}
+	}
+	exceptionStr := toGoString(ctx, exVal)
 	err = fmt.Errorf("%s", exceptionStr)
 	return
 }
@@ -0,0 +1,159 @@
+package quickjs
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestBufferArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []byte
+	}{
+		{"Uint8Array", `bytesOf(new Uint8Array([1, 2, 3]))`, []byte{1, 2, 3}},
+		{"ArrayBuffer", `bytesOf(new Uint8Array([4, 5]).buffer)`, []byte{4, 5}},
+		{"subarray", `bytesOf(new Uint8Array([1, 2, 3, 4]).subarray(1, 3))`, []byte{2, 3}},
+		{"DataView", `bytesOf(new DataView(new Uint8Array([1, 2, 3]).buffer, 2))`, []byte{3}},
+		{"Int16Array", `bytesOf(new Int16Array([0x0201]))`, []byte{1, 2}},
+		{"empty buffer", `bytesOf(new ArrayBuffer(0))`, []byte{}},
+		{"empty array", `bytesOf(new Uint8Array(0))`, []byte{}},
+		{"string", `bytesOf("ab")`, []byte("ab")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			var got []byte
+			bytesOf := func(b []byte) int {
+				got = b
+				return len(b)
+			}
+			if _, err := ctx.Eval(tt.script, map[string]interface{}{"bytesOf": bytesOf}); err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			if got == nil || !bytes.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBufferNotConvertedToBytes(t *testing.T) {
+	ctx := newTestContext(t)
+	var got interface{}
+	valueOf := func(v interface{}) {
+		got = v
+	}
+	if _, err := ctx.Eval(`valueOf(new Uint8Array([1, 2]))`, map[string]interface{}{"valueOf": valueOf}); err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if _, ok := got.([]byte); ok {
+		t.Errorf("a buffer passed as interface{} must not be converted to []byte")
+	}
+}
+
+func TestChannelSendBuffer(t *testing.T) {
+	ctx := newTestContext(t)
+	ch := make(chan []byte, 1)
+	goCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := ctx.EvalAwait(goCtx, `ch.send(new Uint8Array([1, 2]))`, map[string]interface{}{"ch": ch}); err != nil {
+		t.Fatalf("EvalAwait: %v", err)
+	}
+	if got := <-ch; !reflect.DeepEqual(got, []byte{1, 2}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestCyclicValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		wantErr bool
+	}{
+		{"object", `const a = {}; a.self = a; a`, true},
+		{"array", `const a = []; a.push(a); a`, true},
+		{"nested", `const a = {b: {c: []}}; a.b.c.push(a); a`, true},
+		{"shared", `const o = {}; [o, o, {o}]`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			_, err := ctx.Eval(tt.script, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGoValueBackToGo(t *testing.T) {
+	m := map[string]interface{}{"a": 1}
+	p := &struct{ N int }{N: 1}
+	s := []int{1, 2}
+	tests := []struct {
+		name   string
+		script string
+		check  func(v interface{}) bool
+	}{
+		{"map", `m`, func(v interface{}) bool {
+			got, ok := v.(map[string]interface{})
+			return ok && reflect.ValueOf(got).Pointer() == reflect.ValueOf(m).Pointer()
+		}},
+		{"pointer", `p`, func(v interface{}) bool {
+			return v == p
+		}},
+		{"slice", `s`, func(v interface{}) bool {
+			got, ok := v.([]int)
+			return ok && &got[0] == &s[0]
+		}},
+		{"passed to Go func", `same(p)`, func(v interface{}) bool {
+			return v == true
+		}},
+		{"JS object", `({p})`, func(v interface{}) bool {
+			obj, ok := v.(map[string]interface{})
+			return ok && obj["p"] == p
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			same := func(v interface{}) bool {
+				return v == p
+			}
+			res, err := ctx.Eval(tt.script, map[string]interface{}{"m": m, "p": p, "s": s, "same": same})
+			if err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			if !tt.check(res) {
+				t.Errorf("got %#v", res)
+			}
+		})
+	}
+}
+
+// the Go objects left in a closed context are finalized when the runtime is freed by GC.
+func TestCloseWithGoObjectsLeft(t *testing.T) {
+	scripts := []string{
+		`globalThis.keep = m`,
+		`globalThis.keep = {m}`,
+		`globalThis.keep = [m, {m}]; keep.push(keep)`,
+	}
+	for _, script := range scripts {
+		ctx, err := NewContext()
+		if err != nil {
+			t.Fatalf("NewContext: %v", err)
+		}
+		if _, err := ctx.Eval(script, map[string]interface{}{"m": map[string]interface{}{"n": "go"}}); err != nil {
+			t.Fatalf("Eval: %v", err)
+		}
+		ctx.Close()
+	}
+	for i := 0; i < 3; i++ {
+		runtime.GC()
+	}
+}
@@ -0,0 +1,798 @@
+package quickjs
+
+// #include "go-proxy.h"
+import "C"
+import (
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const webGlobalsPrelude = `(function(encode, decode, atob, btoa, parseURL, setURLPart, parseQuery, serializeQuery, clone) {
+	const toArray = list => {
+		const a = [];
+		for (let i = 0; i < list.length; i++) {
+			a.push(list[i]);
+		}
+		return a;
+	};
+	const globals = {};
+
+	class TextEncoder {
+		get encoding() {
+			return "utf-8";
+		}
+		encode(input = "") {
+			return encode(String(input));
+		}
+		encodeInto(source, destination) {
+			source = String(source);
+			let read = 0, written = 0;
+			for (const ch of source) {
+				const cp = ch.codePointAt(0);
+				const n = cp < 0x80 ? 1 : cp < 0x800 ? 2 : cp < 0x10000 ? 3 : 4;
+				if (written + n > destination.length) {
+					break;
+				}
+				read += ch.length;
+				written += n;
+			}
+			destination.set(encode(source.slice(0, read)));
+			return {read, written};
+		}
+		get [Symbol.toStringTag]() {
+			return "TextEncoder";
+		}
+	}
+
+	const encodings = {
+		"utf-8": ["utf-8", "utf8", "unicode-1-1-utf-8", "unicode11utf8", "unicode20utf8", "x-unicode20utf8"],
+		"utf-16le": ["utf-16le", "utf-16", "ucs-2", "unicode", "csunicode", "iso-10646-ucs-2", "unicodefeff"],
+		"utf-16be": ["utf-16be", "unicodefffe"],
+	};
+	const encodingOf = label => {
+		label = String(label).trim().toLowerCase();
+		for (const [encoding, labels] of Object.entries(encodings)) {
+			if (labels.includes(label)) {
+				return encoding;
+			}
+		}
+		throw new RangeError("unsupported encoding: " + label);
+	};
+
+	class TextDecoder {
+		#encoding;
+		#fatal;
+		#ignoreBOM;
+		#rest = null; // the bytes of an incomplete char in streaming
+		#start = true;
+		constructor(label = "utf-8", options) {
+			options = options ?? {};
+			this.#encoding = encodingOf(label);
+			this.#fatal = Boolean(options.fatal);
+			this.#ignoreBOM = Boolean(options.ignoreBOM);
+		}
+		get encoding() {
+			return this.#encoding;
+		}
+		get fatal() {
+			return this.#fatal;
+		}
+		get ignoreBOM() {
+			return this.#ignoreBOM;
+		}
+		decode(input, options) {
+			const stream = Boolean(options?.stream);
+			if (input !== undefined && !(input instanceof ArrayBuffer) && !ArrayBuffer.isView(input)) {
+				throw new TypeError("input must be an ArrayBuffer or a view of it");
+			}
+			const [text, rest, ok] = decode(this.#encoding, this.#rest, input ?? null, !this.#ignoreBOM && this.#start, stream);
+			if (!ok && this.#fatal) {
+				this.#rest = null;
+				this.#start = true;
+				throw new TypeError("the encoded data is not valid " + this.#encoding);
+			}
+			if (stream) {
+				this.#rest = rest.length > 0 ? rest : null;
+				this.#start = this.#start && text.length === 0;
+			} else {
+				this.#rest = null;
+				this.#start = true;
+			}
+			return text;
+		}
+		get [Symbol.toStringTag]() {
+			return "TextDecoder";
+		}
+	}
+	Object.assign(globals, {TextEncoder, TextDecoder});
+
+	globals.atob = function(data) {
+		const [s, ok] = atob(String(data));
+		if (!ok) {
+			throw new DOMException("the string to be decoded is not correctly encoded", "InvalidCharacterError");
+		}
+		return s;
+	};
+	globals.btoa = function(data) {
+		const [s, ok] = btoa(String(data));
+		if (!ok) {
+			throw new DOMException("the string to be encoded contains characters outside of the Latin1 range", "InvalidCharacterError");
+		}
+		return s;
+	};
+
+	let setParamsList, attachParams;
+	class URLSearchParams {
+		#list = []; // [name, value]
+		#update = null; // update the query of the URL
+		constructor(init) {
+			if (init == null) {
+				return;
+			}
+			if (typeof init === "object" || typeof init === "function") {
+				if (typeof init[Symbol.iterator] === "function") {
+					for (const pair of init) {
+						const p = [...pair];
+						if (p.length !== 2) {
+							throw new TypeError("query pair must have exactly 2 items");
+						}
+						this.#list.push([String(p[0]), String(p[1])]);
+					}
+				} else {
+					for (const name of Object.keys(init)) {
+						this.#list.push([name, String(init[name])]);
+					}
+				}
+				return;
+			}
+			init = String(init);
+			this.#list = URLSearchParams.#parse(init.startsWith("?") ? init.substring(1) : init);
+		}
+		static #parse(query) {
+			const pairs = toArray(parseQuery(query)), list = [];
+			for (let i = 0; i + 1 < pairs.length; i += 2) {
+				list.push([pairs[i], pairs[i+1]]);
+			}
+			return list;
+		}
+		static {
+			setParamsList = (params, query) => {
+				params.#list = URLSearchParams.#parse(query.startsWith("?") ? query.substring(1) : query);
+			};
+			attachParams = (params, update) => {
+				params.#update = update;
+			};
+		}
+		#changed() {
+			if (this.#update !== null) {
+				this.#update(this.toString());
+			}
+		}
+		get size() {
+			return this.#list.length;
+		}
+		append(name, value) {
+			this.#list.push([String(name), String(value)]);
+			this.#changed();
+		}
+		delete(name, value) {
+			name = String(name);
+			value = value === undefined ? undefined : String(value);
+			this.#list = this.#list.filter(([n, v]) => n !== name || (value !== undefined && v !== value));
+			this.#changed();
+		}
+		get(name) {
+			name = String(name);
+			const pair = this.#list.find(([n]) => n === name);
+			return pair === undefined ? null : pair[1];
+		}
+		getAll(name) {
+			name = String(name);
+			return this.#list.filter(([n]) => n === name).map(([, v]) => v);
+		}
+		has(name, value) {
+			name = String(name);
+			value = value === undefined ? undefined : String(value);
+			return this.#list.some(([n, v]) => n === name && (value === undefined || v === value));
+		}
+		set(name, value) {
+			name = String(name);
+			value = String(value);
+			const i = this.#list.findIndex(([n]) => n === name);
+			if (i < 0) {
+				this.#list.push([name, value]);
+			} else {
+				this.#list[i] = [name, value];
+				this.#list = this.#list.filter(([n], j) => n !== name || j <= i);
+			}
+			this.#changed();
+		}
+		sort() {
+			// stable sort by the code units of names
+			this.#list.sort(([a], [b]) => a < b ? -1 : a > b ? 1 : 0);
+			this.#changed();
+		}
+		forEach(callback, thisArg) {
+			for (const [name, value] of this) {
+				callback.call(thisArg, value, name, this);
+			}
+		}
+		*entries() {
+			for (let i = 0; i < this.#list.length; i++) {
+				yield [...this.#list[i]];
+			}
+		}
+		*keys() {
+			for (const [name] of this.entries()) {
+				yield name;
+			}
+		}
+		*values() {
+			for (const [, value] of this.entries()) {
+				yield value;
+			}
+		}
+		[Symbol.iterator]() {
+			return this.entries();
+		}
+		toString() {
+			return serializeQuery(this.#list.flat());
+		}
+		get [Symbol.toStringTag]() {
+			return "URLSearchParams";
+		}
+	}
+
+	const specialSchemes = ["http:", "https:", "ws:", "wss:", "ftp:"];
+
+	class URL {
+		#parts; // [href, protocol, username, password, hostname, port, pathname, search, hash]
+		#searchParams;
+		constructor(url, base) {
+			const [parts, ok] = base === undefined ? parseURL(String(url)) : parseURL(String(url), String(base));
+			if (!ok) {
+				throw new TypeError("invalid URL: " + url);
+			}
+			this.#parts = toArray(parts);
+			this.#searchParams = new URLSearchParams(this.search);
+			attachParams(this.#searchParams, query => this.#set("search", query));
+		}
+		#set(name, value) {
+			const [parts, ok] = setURLPart(this.#parts[0], name, String(value));
+			if (!ok) {
+				if (name === "href") {
+					throw new TypeError("invalid URL: " + value);
+				}
+				return;
+			}
+			this.#parts = toArray(parts);
+		}
+		#setAndSync(name, value) {
+			this.#set(name, value);
+			setParamsList(this.#searchParams, this.search);
+		}
+		static canParse(url, base) {
+			return (base === undefined ? parseURL(String(url)) : parseURL(String(url), String(base)))[1];
+		}
+		static parse(url, base) {
+			try {
+				return new URL(url, base);
+			} catch (e) {
+				return null;
+			}
+		}
+		get href() {
+			return this.#parts[0];
+		}
+		set href(v) {
+			this.#setAndSync("href", v);
+		}
+		get origin() {
+			return specialSchemes.includes(this.protocol) ? this.protocol + "//" + this.host : "null";
+		}
+		get protocol() {
+			return this.#parts[1];
+		}
+		set protocol(v) {
+			this.#set("protocol", v);
+		}
+		get username() {
+			return this.#parts[2];
+		}
+		set username(v) {
+			this.#set("username", v);
+		}
+		get password() {
+			return this.#parts[3];
+		}
+		set password(v) {
+			this.#set("password", v);
+		}
+		get host() {
+			return this.#parts[5] === "" ? this.#parts[4] : this.#parts[4] + ":" + this.#parts[5];
+		}
+		set host(v) {
+			this.#set("host", v);
+		}
+		get hostname() {
+			return this.#parts[4];
+		}
+		set hostname(v) {
+			this.#set("hostname", v);
+		}
+		get port() {
+			return this.#parts[5];
+		}
+		set port(v) {
+			this.#set("port", v);
+		}
+		get pathname() {
+			return this.#parts[6];
+		}
+		set pathname(v) {
+			this.#set("pathname", v);
+		}
+		get search() {
+			return this.#parts[7];
+		}
+		set search(v) {
+			this.#setAndSync("search", v);
+		}
+		get searchParams() {
+			return this.#searchParams;
+		}
+		get hash() {
+			return this.#parts[8];
+		}
+		set hash(v) {
+			this.#set("hash", v);
+		}
+		toString() {
+			return this.href;
+		}
+		toJSON() {
+			return this.href;
+		}
+		get [Symbol.toStringTag]() {
+			return "URL";
+		}
+	}
+	Object.assign(globals, {URL, URLSearchParams});
+
+	globals.structuredClone = function(value, options) {
+		try {
+			return clone(value);
+		} catch (e) {
+			throw new DOMException(e.message, "DataCloneError");
+		}
+	};
+
+	for (const [name, value] of Object.entries(globals)) {
+		Object.defineProperty(globalThis, name, {value, writable: true, configurable: true});
+	}
+})`
+
+// EnableWebGlobals adds `TextEncoder`, `TextDecoder`, `URL`, `URLSearchParams`, `atob`, `btoa`
+// and `structuredClone` to the global object, which are implemented with the packages of Go.
+// TextDecoder supports UTF-8 and UTF-16. structuredClone supports the values that can be
+// serialized by quickjs: primitives, plain objects, arrays, Date, ArrayBuffer and typed arrays.
+func (ctx *JsContext) EnableWebGlobals() (err error) {
+	ctx.lock()
+	defer ctx.unlock()
+
+	c := ctx.c
+	if err = defineDOMException(c); err != nil {
+		return
+	}
+	clone := JsValue{ctx: c, v: C.newStructuredClone(c)}
+	defer C.JS_FreeValue(c, clone.v)
+
+	res, e := evalPrelude(c, "<web-globals>", webGlobalsPrelude,
+		encodeUTF8, decodeText, atob, btoa, parseURL, setURLPart, parseQuery, serializeQuery, clone,
+	)
+	if e != nil {
+		err = e
+		return
+	}
+	C.JS_FreeValue(c, res)
+	return
+}
+
+// the lone surrogates of JS strings are converted to 3-byte sequences, which are replaced with U+FFFD.
+func encodeUTF8(s string) Uint8Array {
+	b := make([]byte, 0, len(s))
+	for i:=0; i<len(s); i++ {
+		if s[i] == 0xED && i+2 < len(s) && s[i+1] >= 0xA0 && s[i+1] <= 0xBF {
+			b = append(b, "�"...)
+			i += 2
+			continue
+		}
+		b = append(b, s[i])
+	}
+	return b
+}
+
+// decode the bytes of rest and b. When streaming, the bytes of an incomplete char at the end are
+// returned as rest. ok is false if there are invalid bytes, which are replaced with U+FFFD.
+func decodeText(encoding string, rest []byte, b []byte, stripBOM bool, stream bool) (text string, newRest Uint8Array, ok bool) {
+	if len(rest) > 0 {
+		b = append(rest, b...)
+	}
+	switch encoding {
+	case "utf-16le", "utf-16be":
+		text, newRest, ok = decodeUTF16(b, encoding == "utf-16be", stripBOM, stream)
+	default:
+		text, newRest, ok = decodeUTF8(b, stripBOM, stream)
+	}
+	if newRest == nil {
+		newRest = Uint8Array{}
+	}
+	return
+}
+
+func decodeUTF8(b []byte, stripBOM bool, stream bool) (text string, rest Uint8Array, ok bool) {
+	if stripBOM && len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF {
+		b = b[3:]
+	}
+	ok = true
+	var sb strings.Builder
+	for i:=0; i<len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+		if r != utf8.RuneError || size > 1 {
+			sb.WriteRune(r)
+			i += size
+			continue
+		}
+		n := utf8PrefixLen(b[i:])
+		if i+n == len(b) && n > 0 && stream {
+			rest = append(Uint8Array{}, b[i:]...)
+			break
+		}
+		// a maximal subpart of an invalid sequence is replaced with one U+FFFD
+		ok = false
+		sb.WriteRune(utf8.RuneError)
+		if n == 0 {
+			n = 1
+		}
+		i += n
+	}
+	return sb.String(), rest, ok
+}
+
+// the length of the valid but incomplete UTF-8 sequence at the beginning of b, 0 if it's not.
+func utf8PrefixLen(b []byte) int {
+	lo, hi := byte(0x80), byte(0xBF)
+	var need int
+	switch c := b[0]; {
+	case c >= 0xC2 && c <= 0xDF:
+		need = 1
+	case c == 0xE0:
+		need, lo = 2, 0xA0
+	case c == 0xED:
+		need, hi = 2, 0x9F
+	case c >= 0xE1 && c <= 0xEF:
+		need = 2
+	case c == 0xF0:
+		need, lo = 3, 0x90
+	case c == 0xF4:
+		need, hi = 3, 0x8F
+	case c >= 0xF1 && c <= 0xF3:
+		need = 3
+	default:
+		return 0
+	}
+	n := 1
+	for ; n <= need && n < len(b); n++ {
+		if b[n] < lo || b[n] > hi {
+			break
+		}
+		lo, hi = 0x80, 0xBF
+	}
+	return n
+}
+
+func decodeUTF16(b []byte, bigEndian bool, stripBOM bool, stream bool) (text string, rest Uint8Array, ok bool) {
+	unit := func(i int) uint16 {
+		if bigEndian {
+			return uint16(b[i])<<8 | uint16(b[i+1])
+		}
+		return uint16(b[i+1])<<8 | uint16(b[i])
+	}
+	if stripBOM && len(b) >= 2 && unit(0) == 0xFEFF {
+		b = b[2:]
+	}
+	ok = true
+	var sb strings.Builder
+	i := 0
+	for ; i+1 < len(b); i += 2 {
+		u := unit(i)
+		switch {
+		case !utf16.IsSurrogate(rune(u)):
+			sb.WriteRune(rune(u))
+			continue
+		case u < 0xDC00 && i+3 < len(b):
+			if u2 := unit(i+2); u2 >= 0xDC00 && u2 <= 0xDFFF {
+				sb.WriteRune(utf16.DecodeRune(rune(u), rune(u2)))
+				i += 2
+				continue
+			}
+		case u < 0xDC00 && stream:
+			// the low surrogate may be in the next chunk
+			rest = append(Uint8Array{}, b[i:]...)
+			return sb.String(), rest, ok
+		}
+		ok = false
+		sb.WriteRune(utf8.RuneError)
+	}
+	if i < len(b) {
+		if stream {
+			rest = Uint8Array{b[i]}
+		} else {
+			ok = false
+			sb.WriteRune(utf8.RuneError)
+		}
+	}
+	return sb.String(), rest, ok
+}
+
+// decode data with forgiving-base64, every byte is a char of the result.
+func atob(data string) (res string, ok bool) {
+	data = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\n', '\f', '\r':
+			return -1
+		}
+		return r
+	}, data)
+	if len(data) % 4 == 0 {
+		data = strings.TrimSuffix(data, "=")
+		data = strings.TrimSuffix(data, "=")
+	}
+	if len(data) % 4 == 1 || strings.ContainsAny(data, "=-_") {
+		return
+	}
+	b, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil {
+		return
+	}
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r), true
+}
+
+// encode data with base64, every char of it must be in the range of Latin1.
+func btoa(data string) (res string, ok bool) {
+	b := make([]byte, 0, len(data))
+	for _, r := range data {
+		if r > 0xFF {
+			return
+		}
+		b = append(b, byte(r))
+	}
+	return base64.StdEncoding.EncodeToString(b), true
+}
+
+var defaultPorts = map[string]string{
+	"http": "80",
+	"https": "443",
+	"ws": "80",
+	"wss": "443",
+	"ftp": "21",
+}
+
+// parse rawURL against base if given. The parts are the href, protocol, username, password,
+// hostname, port, pathname, search and hash of the URL.
+func parseURL(rawURL string, base ...string) (parts []string, ok bool) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return
+	}
+	if len(base) > 0 {
+		b, err := url.Parse(strings.TrimSpace(base[0]))
+		if err != nil || !b.IsAbs() {
+			return
+		}
+		u = b.ResolveReference(u)
+	} else {
+		// the dot segments are removed
+		u = u.ResolveReference(u)
+	}
+	if !u.IsAbs() {
+		return
+	}
+
+	_, special := defaultPorts[u.Scheme]
+	if special && u.Host == "" {
+		return
+	}
+	hostname, port := strings.ToLower(u.Hostname()), u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if strings.Contains(hostname, ":") {
+		hostname = "[" + hostname + "]"
+	}
+	u.Host = hostname
+	if port != "" {
+		u.Host += ":" + port
+	}
+	if special && u.Path == "" {
+		u.Path = "/"
+	}
+
+	var username, password string
+	if u.User != nil {
+		username, password, _ = strings.Cut(u.User.String(), ":")
+	}
+	pathname := u.Opaque
+	if pathname == "" {
+		pathname = u.EscapedPath()
+	}
+	var search, hash string
+	if u.RawQuery != "" {
+		search = "?" + u.RawQuery
+	}
+	if u.Fragment != "" {
+		hash = "#" + u.EscapedFragment()
+	}
+	return []string{u.String(), u.Scheme + ":", username, password, hostname, port, pathname, search, hash}, true
+}
+
+// set the part of the URL href by building a new URL, ok is false if the new one is invalid.
+func setURLPart(href string, name string, value string) (parts []string, ok bool) {
+	if name == "href" {
+		return parseURL(value)
+	}
+	p, ok := parseURL(href)
+	if !ok {
+		return
+	}
+	protocol, username, password, hostname, port, pathname, search, hash := p[1], p[2], p[3], p[4], p[5], p[6], p[7], p[8]
+	opaque := !strings.HasPrefix(href[len(protocol):], "//")
+
+	switch name {
+	case "protocol":
+		scheme, _, _ := strings.Cut(value, ":")
+		protocol = scheme + ":"
+	case "username":
+		username = url.PathEscape(value)
+	case "password":
+		password = url.PathEscape(value)
+	case "host":
+		if strings.ContainsAny(value, "/?#@") {
+			return nil, false
+		}
+		hostname, port = value, ""
+	case "hostname":
+		if strings.ContainsAny(value, "/?#@") {
+			return nil, false
+		}
+		hostname = value
+	case "port":
+		// the leading digits are taken
+		digits := value
+		if i := strings.IndexFunc(value, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+			digits = value[:i]
+		}
+		if digits == "" && value != "" {
+			return nil, false
+		}
+		if n, err := strconv.Atoi(digits); digits != "" && (err != nil || n > 65535) {
+			return nil, false
+		}
+		port = digits
+	case "pathname":
+		if opaque {
+			return nil, false
+		}
+		pathname = strings.NewReplacer("?", "%3F", "#", "%23").Replace(value)
+		if !strings.HasPrefix(pathname, "/") {
+			pathname = "/" + pathname
+		}
+	case "search":
+		search = strings.ReplaceAll(strings.TrimPrefix(value, "?"), "#", "%23")
+		if search != "" {
+			search = "?" + search
+		}
+	case "hash":
+		hash = strings.TrimPrefix(value, "#")
+		if hash != "" {
+			hash = "#" + hash
+		}
+	default:
+		return nil, false
+	}
+
+	var sb strings.Builder
+	sb.WriteString(protocol)
+	if !opaque {
+		sb.WriteString("//")
+		if username != "" || password != "" {
+			sb.WriteString(username)
+			if password != "" {
+				sb.WriteString(":" + password)
+			}
+			sb.WriteString("@")
+		}
+		sb.WriteString(hostname)
+		if port != "" {
+			sb.WriteString(":" + port)
+		}
+	}
+	sb.WriteString(pathname)
+	sb.WriteString(search)
+	sb.WriteString(hash)
+	return parseURL(sb.String())
+}
+
+// parse the application/x-www-form-urlencoded query, the result is the pairs of names and values.
+func parseQuery(query string) (pairs []string) {
+	pairs = []string{}
+	for _, s := range strings.Split(query, "&") {
+		if s == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(s, "=")
+		pairs = append(pairs, unescapeQuery(name), unescapeQuery(value))
+	}
+	return
+}
+
+// "+" is decoded to space, and the invalid escapes are kept.
+func unescapeQuery(s string) string {
+	b := make([]byte, 0, len(s))
+	for i:=0; i<len(s); i++ {
+		switch c := s[i]; c {
+		case '+':
+			b = append(b, ' ')
+		case '%':
+			if i+2 < len(s) {
+				if n, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+					b = append(b, byte(n))
+					i += 2
+					continue
+				}
+			}
+			b = append(b, c)
+		default:
+			b = append(b, c)
+		}
+	}
+	return strings.ToValidUTF8(string(b), "�")
+}
+
+// serialize the pairs of names and values to application/x-www-form-urlencoded.
+func serializeQuery(pairs []string) string {
+	var sb strings.Builder
+	for i:=0; i+1<len(pairs); i+=2 {
+		if i > 0 {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(escapeQuery(pairs[i]))
+		sb.WriteByte('=')
+		sb.WriteString(escapeQuery(pairs[i+1]))
+	}
+	return sb.String()
+}
+
+func escapeQuery(s string) string {
+	const hex = "0123456789ABCDEF"
+	b := make([]byte, 0, len(s))
+	for _, c := range encodeUTF8(s) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '*', c == '-', c == '.', c == '_':
+			b = append(b, c)
+		case c == ' ':
+			b = append(b, '+')
+		default:
+			b = append(b, '%', hex[c>>4], hex[c&15])
+		}
+	}
+	return string(b)
+}
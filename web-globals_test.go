@@ -0,0 +1,107 @@
+package quickjs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWebGlobals(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		want    interface{}
+		wantErr string
+	}{
+		// TextEncoder and TextDecoder
+		{name: "encode", script: `Array.from(new TextEncoder().encode("aé€😀"))`,
+			want: []interface{}{int64(0x61), int64(0xc3), int64(0xa9), int64(0xe2), int64(0x82), int64(0xac),
+				int64(0xf0), int64(0x9f), int64(0x98), int64(0x80)}},
+		{name: "encode lone surrogate", script: `Array.from(new TextEncoder().encode("\ud800"))`,
+			want: []interface{}{int64(0xef), int64(0xbf), int64(0xbd)}},
+		{name: "encodeInto", script: `const r = new TextEncoder().encodeInto("a€b", new Uint8Array(3)); [r.read, r.written]`,
+			want: []interface{}{int64(1), int64(1)}},
+		{name: "decode", script: `new TextDecoder().decode(new Uint8Array([0xef, 0xbb, 0xbf, 0x61, 0xc3, 0xa9]))`, want: "aé"},
+		{name: "decode ignoreBOM", script: `new TextDecoder("utf-8", {ignoreBOM: true}).decode(new Uint8Array([0xef, 0xbb, 0xbf, 0x61]))`,
+			want: "\ufeffa"},
+		{name: "decode invalid", script: `new TextDecoder().decode(new Uint8Array([0x61, 0xff]))`, want: "a�"},
+		{name: "decode fatal", script: `new TextDecoder("utf8", {fatal: true}).decode(new Uint8Array([0xff]))`, wantErr: "TypeError"},
+		{name: "decode stream", script: `
+			const d = new TextDecoder();
+			d.decode(new Uint8Array([0xe2, 0x82]), {stream: true}) + "|" + d.decode(new Uint8Array([0xac]))`, want: "|€"},
+		{name: "decode utf-16le", script: `new TextDecoder("utf-16le").decode(new Uint8Array([0x61, 0, 0xac, 0x20]))`, want: "a€"},
+		{name: "decode utf-16be", script: `new TextDecoder("utf-16be").decode(new Uint8Array([0, 0x61]).buffer)`, want: "a"},
+		{name: "unsupported encoding", script: `new TextDecoder("latin1")`, wantErr: "RangeError"},
+
+		// atob and btoa
+		{name: "btoa", script: `btoa("hello\xff")`, want: "aGVsbG//"},
+		{name: "atob", script: `atob(" aGVs bG8 ")`, want: "hello"},
+		{name: "btoa out of latin1", script: `btoa("€")`, wantErr: "InvalidCharacterError"},
+		{name: "atob invalid", script: `atob("a")`, wantErr: "InvalidCharacterError"},
+
+		// URL
+		{name: "url parts", script: `
+			const u = new URL("https://user:pw@example.com:8080/a/b?x=1#frag");
+			[u.protocol, u.username, u.password, u.host, u.hostname, u.port, u.pathname, u.search, u.hash, u.origin]`,
+			want: []interface{}{"https:", "user", "pw", "example.com:8080", "example.com", "8080", "/a/b", "?x=1", "#frag",
+				"https://example.com:8080"}},
+		{name: "url base", script: `new URL("../c?y=2", "http://example.com/a/b/").href`, want: "http://example.com/a/c?y=2"},
+		{name: "url invalid", script: `new URL("no scheme")`, wantErr: "TypeError"},
+		{name: "url canParse", script: `[URL.canParse("http://a"), URL.canParse("::"), URL.parse("::")]`,
+			want: []interface{}{true, false, nil}},
+		{name: "url setters", script: `
+			const u = new URL("http://example.com/a");
+			u.pathname = "/b"; u.port = "81"; u.hash = "h";
+			u.href`, want: "http://example.com:81/b#h"},
+		{name: "url searchParams", script: `
+			const u = new URL("http://example.com/?a=1");
+			u.searchParams.append("b", "x y");
+			u.search = "?c=3&c=4";
+			[u.href, u.searchParams.getAll("c")]`,
+			want: []interface{}{"http://example.com/?c=3&c=4", []interface{}{"3", "4"}}},
+		{name: "url json", script: `JSON.stringify({u: new URL("http://example.com")})`, want: `{"u":"http://example.com/"}`},
+
+		// URLSearchParams
+		{name: "params string", script: `const p = new URLSearchParams("?a=1&b=x+y&a=%20"); [p.get("b"), p.getAll("a"), p.size]`,
+			want: []interface{}{"x y", []interface{}{"1", " "}, int64(3)}},
+		{name: "params object", script: `new URLSearchParams({b: 2, a: "é&"}).toString()`, want: "b=2&a=%C3%A9%26"},
+		{name: "params pairs", script: `new URLSearchParams([["a", 1], ["a", 2]]).toString()`, want: "a=1&a=2"},
+		{name: "params bad pair", script: `new URLSearchParams([["a"]])`, wantErr: "TypeError"},
+		{name: "params edit", script: `
+			const p = new URLSearchParams("c=1&a=2&c=3&b=4");
+			p.set("c", "5"); p.delete("b"); p.sort();
+			[p.toString(), p.has("c", "5"), p.has("b"), p.get("none")]`,
+			want: []interface{}{"a=2&c=5", true, false, nil}},
+		{name: "params iterate", script: `[...new URLSearchParams("a=1&b=2").keys()].join() + [...new URLSearchParams("a=1&b=2").values()].join()`,
+			want: "a,b1,2"},
+
+		// structuredClone
+		{name: "clone", script: `
+			const v = {a: [1, {b: "c"}], d: new Date(0), u: new Uint8Array([1, 2])};
+			const c = structuredClone(v);
+			[c !== v, c.a[1] !== v.a[1], c.a[1].b, c.d instanceof Date, c.d.getTime(), c.u instanceof Uint8Array, c.u[1]]`,
+			want: []interface{}{true, true, "c", true, int64(0), true, int64(2)}},
+		{name: "clone function", script: `structuredClone(() => 1)`, wantErr: "DataCloneError"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			if err := ctx.EnableWebGlobals(); err != nil {
+				t.Fatalf("EnableWebGlobals: %v", err)
+			}
+			res, err := ctx.Eval(tt.script, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("got %#v, want %#v", res, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package quickjs
+
+// #include "go-proxy.h"
+import "C"
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+const (
+	maxSafeInteger  = 1<<53 - 1 // Number.MAX_SAFE_INTEGER
+	maxMessageDepth = 1000
+)
+
+// the messages are transferred as the bytes written by JS_WriteObject. The values sent by Go are
+// converted in a context of the worker's own, which is created by the first message, and the
+// messages posted by the script are read by JS_ReadObject in the worker's context directly.
+type messageCodec struct {
+	dateClass C.JSClassID
+
+	mu     sync.Mutex
+	ctx    *JsContext // the context converting the values sent
+	closed bool
+}
+
+func newMessageCodec(c *C.JSContext) *messageCodec {
+	date := C.JS_NewDate(c, 0)
+	defer C.JS_FreeValue(c, date)
+	return &messageCodec{dateClass: C.JS_GetClassID(date)}
+}
+
+func (m *messageCodec) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	if m.ctx != nil {
+		m.ctx.Close()
+		m.ctx = nil
+	}
+}
+
+// convert v to a JS value, and write it with the structured serialization.
+func (m *messageCodec) encode(v interface{}) (msg []byte, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		err = ErrWorkerClosed
+		return
+	}
+	if m.ctx == nil {
+		if m.ctx, err = NewContext(); err != nil {
+			return
+		}
+	}
+	ctx := m.ctx
+	ctx.lock()
+	defer ctx.unlock()
+
+	c := ctx.c
+	jsVal, e := m.toJs(c, reflect.ValueOf(v), 0)
+	if e != nil {
+		err = e
+		return
+	}
+	defer C.JS_FreeValue(c, jsVal)
+	var size C.size_t
+	buf := C.JS_WriteObject(c, &size, jsVal, 0)
+	if buf == nil {
+		err = fromJsException(c)
+		return
+	}
+	defer C.js_free(c, unsafe.Pointer(buf))
+	msg = C.GoBytes(unsafe.Pointer(buf), C.int(size))
+	return
+}
+
+// read the bytes written by JS_WriteObject in c, which is locked by the caller, and convert the value to golang.
+func (m *messageCodec) decode(c *C.JSContext, msg []byte) (v interface{}, err error) {
+	jsVal := readMessage(c, msg)
+	if C.JS_IsException(jsVal) != 0 {
+		err = fromJsException(c)
+		return
+	}
+	defer C.JS_FreeValue(c, jsVal)
+	return m.fromJs(c, jsVal, nil)
+}
+
+func readMessage(ctx *C.JSContext, msg []byte) C.JSValue {
+	if len(msg) == 0 {
+		return C.toUndefined()
+	}
+	return C.JS_ReadObject(ctx, (*C.uint8_t)(unsafe.Pointer(&msg[0])), C.size_t(len(msg)), 0)
+}
+
+// the value made is plain, which has no golang objects. []byte is converted to Uint8Array,
+// time.Time to Date, the integers not safe in JS to BigInt, the maps to objects, and the structs
+// to objects with the exported fields named by the `json` tags.
+func (m *messageCodec) toJs(c *C.JSContext, v reflect.Value, depth int) (C.JSValue, error) {
+	if depth > maxMessageDepth {
+		return C.toUndefined(), fmt.Errorf("message is nested too deeply")
+	}
+	if !v.IsValid() {
+		return C.toNull(), nil
+	}
+	if !v.CanInterface() {
+		return C.toUndefined(), fmt.Errorf("%v cannot be sent in message", v.Type())
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return C.JS_NewDate(c, C.double(t.UnixMilli())), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return C.toNull(), nil
+		}
+		return m.toJs(c, v.Elem(), depth+1)
+	case reflect.Bool:
+		if v.Bool() {
+			return C.toTrue(), nil
+		}
+		return C.toFalse(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		if i > maxSafeInteger || i < -maxSafeInteger {
+			return C.JS_NewBigInt64(c, C.int64_t(i)), nil
+		}
+		return C.JS_NewInt64(c, C.int64_t(i)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		if u > maxSafeInteger {
+			return C.JS_NewBigUint64(c, C.uint64_t(u)), nil
+		}
+		return C.JS_NewInt64(c, C.int64_t(u)), nil
+	case reflect.Float32, reflect.Float64:
+		return C.JS_NewFloat64(c, C.double(v.Float())), nil
+	case reflect.String:
+		return makeString(c, v.String()), nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return C.toNull(), nil
+		}
+		if isBytesType(v.Type()) {
+			return makeUint8Array(c, v.Bytes()), nil
+		}
+		fallthrough
+	case reflect.Array:
+		arr := C.JS_NewArray(c)
+		for i:=0; i<v.Len(); i++ {
+			e, err := m.toJs(c, v.Index(i), depth+1)
+			if err != nil {
+				C.JS_FreeValue(c, arr)
+				return C.toUndefined(), err
+			}
+			C.JS_SetPropertyUint32(c, arr, C.uint32_t(i), e)
+		}
+		return arr, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return C.toNull(), nil
+		}
+		return m.mapToJs(c, v, depth)
+	case reflect.Struct:
+		return m.structToJs(c, v, depth)
+	default:
+		return C.toUndefined(), fmt.Errorf("%v cannot be sent in message", v.Type())
+	}
+}
+
+// the keys are converted to strings like encoding/json.
+func (m *messageCodec) mapToJs(c *C.JSContext, v reflect.Value, depth int) (C.JSValue, error) {
+	keys := make([]string, 0, v.Len())
+	values := make(map[string]reflect.Value, v.Len())
+	for iter := v.MapRange(); iter.Next(); {
+		k := iter.Key()
+		if k.Kind() == reflect.Interface && !k.IsNil() {
+			k = k.Elem()
+		}
+		var name string
+		switch k.Kind() {
+		case reflect.String:
+			name = k.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			name = fmt.Sprint(k.Interface())
+		default:
+			return C.toUndefined(), fmt.Errorf("%v cannot be the key of map in message", k.Type())
+		}
+		keys = append(keys, name)
+		values[name] = iter.Value()
+	}
+	sort.Strings(keys)
+
+	obj := C.JS_NewObject(c)
+	for _, k := range keys {
+		e, err := m.toJs(c, values[k], depth+1)
+		if err != nil {
+			C.JS_FreeValue(c, obj)
+			return C.toUndefined(), err
+		}
+		setProperty(c, obj, k, e)
+	}
+	return obj, nil
+}
+
+func (m *messageCodec) structToJs(c *C.JSContext, v reflect.Value, depth int) (C.JSValue, error) {
+	t := v.Type()
+	obj := C.JS_NewObject(c)
+	for i:=0; i<t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// unexported
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			if n := strings.Split(tag, ",")[0]; n != "" {
+				name = n
+			}
+		}
+		e, err := m.toJs(c, v.Field(i), depth+1)
+		if err != nil {
+			C.JS_FreeValue(c, obj)
+			return C.toUndefined(), err
+		}
+		setProperty(c, obj, name, e)
+	}
+	return obj, nil
+}
+
+// set a property with a golang string as the name, the ownership of val is taken
+func setProperty(ctx *C.JSContext, obj C.JSValue, name string, val C.JSValue) {
+	var cName *C.char
+	var nameLen C.int
+	getStrPtrLen(&name, &cName, &nameLen)
+	atom := C.JS_NewAtomLen(ctx, cName, C.size_t(nameLen))
+	C.JS_SetProperty(ctx, obj, atom, val)
+	C.JS_FreeAtom(ctx, atom)
+}
+
+// ArrayBuffer and typed arrays are converted to []byte, Date to time.Time, and BigInt to int64,
+// or *big.Int if it's out of the range of int64.
+func (m *messageCodec) fromJs(c *C.JSContext, jsVal C.JSValue, path jsObjectPath) (goVal interface{}, err error) {
+	if C.JS_IsBigInt(c, jsVal) != 0 {
+		b, ok := new(big.Int).SetString(toGoString(c, jsVal), 10)
+		if !ok {
+			return nil, fmt.Errorf("failed to convert BigInt")
+		}
+		if b.IsInt64() {
+			return b.Int64(), nil
+		}
+		return b, nil
+	}
+	if C.JS_IsObject(jsVal) == 0 {
+		return fromJsValue(c, jsVal)
+	}
+	if b, ok := getBufferBytes(c, jsVal); ok {
+		return b, nil
+	}
+	if C.JS_GetClassID(jsVal) == m.dateClass {
+		var ms C.double
+		C.JS_ToFloat64(c, &ms, jsVal)
+		if math.IsNaN(float64(ms)) {
+			// Invalid Date
+			return nil, nil
+		}
+		return time.UnixMilli(int64(ms)), nil
+	}
+
+	if err = path.enter(jsVal); err != nil {
+		return
+	}
+	defer path.leave(jsVal)
+	elem := func(eJsV C.JSValue) (interface{}, error) {
+		return m.fromJs(c, eJsV, path)
+	}
+	if C.JS_IsArray(c, jsVal) != 0 {
+		return fromJsArray(c, jsVal, elem)
+	}
+	return fromJsObject(c, jsVal, elem)
+}
@@ -0,0 +1,327 @@
+package quickjs
+
+/*
+#include "go-proxy.h"
+#include <stdlib.h>
+
+static int interruptWorker(JSRuntime *rt, void *opaque) {
+	return __atomic_load_n((int*)opaque, __ATOMIC_RELAXED);
+}
+
+static int *newInterruptFlag(JSRuntime *rt) {
+	int *flag = calloc(1, sizeof(int));
+	if (flag != NULL) {
+		JS_SetInterruptHandler(rt, interruptWorker, flag);
+	}
+	return flag;
+}
+
+static void setInterruptFlag(int *flag) {
+	__atomic_store_n(flag, 1, __ATOMIC_RELAXED);
+}
+
+static void freeInterruptFlag(JSRuntime *rt, int *flag) {
+	JS_SetInterruptHandler(rt, NULL, NULL);
+	free(flag);
+}
+*/
+import "C"
+import (
+	"context"
+	"errors"
+	"sync"
+	"fmt"
+)
+
+var (
+	ErrWorkerClosed = errors.New("worker is closed")
+	ErrWorkerTerminated = errors.New("worker is terminated")
+)
+
+const workerPrelude = `(function(serialize, post, close) {
+	const listeners = new Set();
+	const defineGlobal = (name, value) => {
+		Object.defineProperty(globalThis, name, {value, writable: true, configurable: true});
+	};
+	defineGlobal("self", globalThis);
+	defineGlobal("postMessage", function postMessage(data) {
+		let msg;
+		try {
+			msg = serialize(data);
+		} catch (e) {
+			throw new DOMException(e.message, "DataCloneError");
+		}
+		post(msg);
+	});
+	defineGlobal("close", function() {
+		close();
+	});
+	defineGlobal("addEventListener", function(type, listener) {
+		if (type === "message" && typeof listener === "function") {
+			listeners.add(listener);
+		}
+	});
+	defineGlobal("removeEventListener", function(type, listener) {
+		if (type === "message") {
+			listeners.delete(listener);
+		}
+	});
+	globalThis.onmessage = null;
+
+	// deliver the message sent by Go
+	return function(data) {
+		const event = {type: "message", data};
+		if (typeof globalThis.onmessage === "function") {
+			globalThis.onmessage(event);
+		}
+		for (const listener of listeners) {
+			listener.call(globalThis, event);
+		}
+	};
+})`
+
+// WorkerOptions are the options of NewWorker.
+type WorkerOptions struct {
+	Env        map[string]interface{}     // the global vars of the worker
+	Setup      func(ctx *JsContext) error // called before the script is run, to enable fetch, set the module loader and so on
+	BufferSize int                        // the buffer size of the channel returned by Recv
+}
+
+// Worker runs a script in its own context and goroutine, which communicates with Go by messages.
+type Worker struct {
+	ctx     *JsContext
+	deliver uint32 // id of the held function delivering messages
+	codec   *messageCodec
+	out     chan interface{}
+	stop    chan struct{}
+	done    chan struct{}
+	cancel  context.CancelFunc
+	err     error
+
+	mu         sync.Mutex
+	closed     bool // no more messages are accepted
+	closing    bool // close() is called by the script, the messages are dropped
+	terminated bool
+	ended      bool
+	interrupt  *C.int
+}
+
+// NewWorker creates a context, and runs script in a new goroutine. In the script, `postMessage(data)`
+// sends data to the channel returned by Recv, which is copied by the structured serialization of
+// quickjs and converted to the golang value. The value sent by Send is passed to `onmessage` or the
+// listeners added by `addEventListener("message", listener)` as `event.data`. The worker ends when
+// the script calls `close()`, Close is called and the work is done, or Terminate is called.
+func NewWorker(script string, opts *WorkerOptions) (w *Worker, err error) {
+	if opts == nil {
+		opts = &WorkerOptions{}
+	}
+	ctx, e := NewContext()
+	if e != nil {
+		err = e
+		return
+	}
+
+	goCtx, cancel := context.WithCancel(context.Background())
+	w = &Worker{
+		ctx: ctx,
+		out: make(chan interface{}, opts.BufferSize),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+		cancel: cancel,
+	}
+	if err = w.init(); err == nil && opts.Setup != nil {
+		err = opts.Setup(ctx)
+	}
+	if err != nil {
+		w.free()
+		cancel()
+		return nil, err
+	}
+
+	go w.run(goCtx, script, opts.Env)
+	return
+}
+
+func (w *Worker) init() (err error) {
+	ctx := w.ctx
+	ctx.lock()
+	defer ctx.unlock()
+
+	c := ctx.c
+	if err = defineDOMException(c); err != nil {
+		return
+	}
+	serialize := JsValue{ctx: c, v: C.newSerializer(c)}
+	defer C.JS_FreeValue(c, serialize.v)
+
+	deliver, e := evalPrelude(c, "<worker>", workerPrelude, serialize, w.post, w.closeByScript)
+	if e != nil {
+		err = e
+		return
+	}
+	w.deliver = ctx.hold(deliver)
+	w.codec = newMessageCodec(c)
+	if w.interrupt = C.newInterruptFlag(ctx.rt.rt); w.interrupt == nil {
+		err = fmt.Errorf("failed to set interrupt handler")
+		return
+	}
+
+	// the worker is kept running to receive messages until it's closed
+	ctx.tasks.start()
+	return
+}
+
+func (w *Worker) run(goCtx context.Context, script string, env map[string]interface{}) {
+	defer close(w.done)
+	defer close(w.out)
+
+	_, err := w.ctx.EvalContext(goCtx, script, env)
+	if err == nil {
+		err = w.ctx.Run(goCtx)
+	}
+
+	w.mu.Lock()
+	w.closeInbox()
+	if w.terminated {
+		err = ErrWorkerTerminated
+	}
+	w.err = err
+	w.ended = true
+	w.mu.Unlock()
+
+	w.free()
+	w.cancel()
+}
+
+func (w *Worker) free() {
+	ctx := w.ctx
+	ctx.lock()
+	if w.interrupt != nil {
+		C.freeInterruptFlag(ctx.rt.rt, w.interrupt)
+		w.interrupt = nil
+	}
+	ctx.unlock()
+	ctx.Close()
+	if w.codec != nil {
+		w.codec.close()
+	}
+}
+
+// Send sends v to the worker, which is converted to a JS value and copied by the structured serialization
+// to be passed to `onmessage`. []byte is converted to Uint8Array, time.Time to Date, the integers not safe
+// in JS to BigInt, the maps with string or integer keys to objects, and the structs to objects with the
+// exported fields named by the `json` tags.
+func (w *Worker) Send(v interface{}) error {
+	msg, err := w.codec.encode(v)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrWorkerClosed
+	}
+	tasks := w.ctx.tasks
+	tasks.start()
+	tasks.post(func() error {
+		return w.deliverMessage(msg)
+	})
+	return nil
+}
+
+// Recv returns the channel receiving the messages posted by the worker, which is closed when the worker ends.
+func (w *Worker) Recv() <-chan interface{} {
+	return w.out
+}
+
+// Close stops accepting messages, the worker ends when the messages sent and the pending works, such as
+// timers, are done.
+func (w *Worker) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closeInbox()
+}
+
+// Terminate stops the worker immediately, even if the script is running.
+func (w *Worker) Terminate() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ended || w.terminated {
+		return
+	}
+	w.terminated = true
+	w.closeInbox()
+	C.setInterruptFlag(w.interrupt)
+	close(w.stop)
+	w.cancel()
+}
+
+// Done returns a channel closed when the worker ends.
+func (w *Worker) Done() <-chan struct{} {
+	return w.done
+}
+
+// Err returns the error ending the worker after Done is closed: nil if the worker is closed, the
+// exception thrown by the script, or ErrWorkerTerminated.
+func (w *Worker) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// called with w.mu locked.
+func (w *Worker) closeInbox() {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	w.ctx.tasks.cancel()
+}
+
+// `close()` called by the script, the pending messages and timers are dropped.
+func (w *Worker) closeByScript(ctx *JsContext) {
+	w.mu.Lock()
+	w.closing = true
+	w.closeInbox()
+	w.mu.Unlock()
+	ctx.clearTimers()
+}
+
+// `postMessage()` called by the script with the serialized data, which is read in the worker's context.
+func (w *Worker) post(ctx *JsContext, msg []byte) error {
+	data, err := w.codec.decode(ctx.c, msg)
+	if err != nil {
+		return err
+	}
+	select {
+	case w.out <- data:
+		return nil
+	case <-w.stop:
+		return ErrWorkerTerminated
+	}
+}
+
+// run on the thread of the worker.
+func (w *Worker) deliverMessage(msg []byte) error {
+	ctx := w.ctx
+	w.mu.Lock()
+	closing := w.closing
+	w.mu.Unlock()
+	if closing || ctx.c == nil {
+		return nil
+	}
+
+	c := ctx.c
+	data := readMessage(c, msg)
+	if C.JS_IsException(data) != 0 {
+		return fromJsException(c)
+	}
+	defer C.JS_FreeValue(c, data)
+	r := C.JS_Call(c, ctx.held[w.deliver], C.toUndefined(), 1, &data)
+	if C.JS_IsException(r) != 0 {
+		return fromJsException(c)
+	}
+	C.JS_FreeValue(c, r)
+	return nil
+}
@@ -0,0 +1,205 @@
+package quickjs
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// receive a message from w, or fail after a while.
+func recvMessage(t *testing.T, w *Worker) interface{} {
+	t.Helper()
+	select {
+	case v, ok := <-w.Recv():
+		if !ok {
+			t.Fatalf("worker ended: %v", w.Err())
+		}
+		return v
+	case <-time.After(5*time.Second):
+		t.Fatalf("no message received")
+	}
+	return nil
+}
+
+func newTestWorker(t *testing.T, script string) *Worker {
+	t.Helper()
+	w, err := NewWorker(script, nil)
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+	t.Cleanup(func() {
+		w.Terminate()
+		<-w.Done()
+	})
+	return w
+}
+
+func TestWorkerMessages(t *testing.T) {
+	date := time.Date(2024, 5, 6, 7, 8, 9, 10e6, time.UTC)
+	type point struct {
+		X      int    `json:"x"`
+		Y      int    `json:"y,omitempty"`
+		Hidden string `json:"-"`
+		hidden string
+	}
+	tests := []struct {
+		name string
+		send interface{}
+		want interface{}
+		typ  string // the type seen by the worker
+	}{
+		{"nil", nil, nil, "object"},
+		{"bool", true, true, "boolean"},
+		{"int", 42, int64(42), "number"},
+		{"float", 1.5, 1.5, "number"},
+		{"int64", int64(math.MaxInt64), int64(math.MaxInt64), "bigint"},
+		{"uint64", uint64(1) << 60, int64(1) << 60, "bigint"},
+		{"string", "hello", "hello", "string"},
+		{"bytes", []byte{1, 2, 3}, []byte{1, 2, 3}, "Uint8Array"},
+		{"empty bytes", []byte{}, []byte{}, "Uint8Array"},
+		{"time", date, date, "Date"},
+		{"slice", []int{1, 2}, []interface{}{int64(1), int64(2)}, "Array"},
+		{"string map", map[string]interface{}{"a": 1, "b": []byte{1}}, map[string]interface{}{"a": int64(1), "b": []byte{1}}, "Object"},
+		{"int map", map[int]string{2: "b", 1: "a"}, map[string]interface{}{"1": "a", "2": "b"}, "Object"},
+		{"struct", point{X: 1, Hidden: "h", hidden: "h"}, map[string]interface{}{"x": int64(1), "y": int64(0)}, "Object"},
+		{"pointer", &point{X: 2, Y: 3}, map[string]interface{}{"x": int64(2), "y": int64(3)}, "Object"},
+	}
+	w := newTestWorker(t, `
+		const typeOf = v => {
+			if (typeof v !== "object" || v === null) {
+				return typeof v;
+			}
+			return Object.prototype.toString.call(v).slice(8, -1);
+		};
+		onmessage = e => postMessage([typeOf(e.data), e.data]);
+	`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := w.Send(tt.send); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			res := recvMessage(t, w).([]interface{})
+			if res[0] != tt.typ {
+				t.Errorf("got type %v, want %v", res[0], tt.typ)
+			}
+			got := res[1]
+			if tm, ok := got.(time.Time); ok {
+				if !tm.Equal(date) {
+					t.Errorf("got %v, want %v", tm, date)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkerPostMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   interface{}
+	}{
+		{"typed array", `postMessage(new Uint16Array([0x0201]))`, []byte{1, 2}},
+		{"ArrayBuffer", `postMessage(new Uint8Array([1, 2]).buffer)`, []byte{1, 2}},
+		{"Date", `postMessage(new Date(1000))`, time.UnixMilli(1000)},
+		{"BigInt", `postMessage(2n ** 60n)`, int64(1) << 60},
+		{"min int64 BigInt", `postMessage(-(2n ** 63n))`, int64(math.MinInt64)},
+		{"big BigInt", `postMessage([2n ** 64n, -(2n ** 63n) - 1n])`, []interface{}{
+			new(big.Int).Lsh(big.NewInt(1), 64),
+			new(big.Int).Sub(big.NewInt(math.MinInt64), big.NewInt(1)),
+		}},
+		{"shared objects", `const o = {a: 1}; postMessage([o, o])`, []interface{}{map[string]interface{}{"a": int64(1)}, map[string]interface{}{"a": int64(1)}}},
+		{"cyclic", `
+			const a = {};
+			a.self = a;
+			try {
+				postMessage(a);
+			} catch (e) {
+				postMessage(e instanceof DOMException ? e.name : String(e));
+			}`, "DataCloneError"},
+		{"function", `
+			try {
+				postMessage({f() {}});
+			} catch (e) {
+				postMessage(e.name);
+			}`, "DataCloneError"},
+		{"Map", `
+			try {
+				postMessage(new Map());
+			} catch (e) {
+				postMessage(e.name);
+			}`, "DataCloneError"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWorker(t, tt.script)
+			if got := recvMessage(t, w); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkerSendUnsupported(t *testing.T) {
+	w := newTestWorker(t, `onmessage = e => postMessage(e.data)`)
+	if err := w.Send(func() {}); err == nil {
+		t.Errorf("a func is expected to be rejected")
+	}
+	if err := w.Send(map[bool]int{true: 1}); err == nil {
+		t.Errorf("a map with bool keys is expected to be rejected")
+	}
+	type node struct {
+		Next *node
+	}
+	n := &node{}
+	n.Next = n
+	if err := w.Send(n); err == nil {
+		t.Errorf("a cyclic value is expected to be rejected")
+	}
+}
+
+func TestWorkerEnd(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		end     func(w *Worker)
+		wantErr error
+	}{
+		{"close", `onmessage = e => postMessage(e.data)`, (*Worker).Close, nil},
+		{"closed by script", `onmessage = e => close()`, func(w *Worker) { w.Send(1) }, nil},
+		{"terminate", `onmessage = e => { for (;;) {} }`, func(w *Worker) { w.Send(1); w.Terminate() }, ErrWorkerTerminated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWorker(t, tt.script)
+			tt.end(w)
+			select {
+			case <-w.Done():
+			case <-time.After(5*time.Second):
+				t.Fatalf("worker is not ended")
+			}
+			if err := w.Err(); err != tt.wantErr {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+			if err := w.Send(1); err != ErrWorkerClosed {
+				t.Errorf("Send after the end: got %v, want %v", err, ErrWorkerClosed)
+			}
+			if w.codec.ctx != nil {
+				t.Errorf("the context converting the messages is not closed")
+			}
+		})
+	}
+}
+
+func TestWorkerException(t *testing.T) {
+	w := newTestWorker(t, `throw new Error("boom")`)
+	<-w.Done()
+	if err := w.Err(); err == nil || err == ErrWorkerTerminated {
+		t.Errorf("got %v, want the exception", err)
+	}
+}